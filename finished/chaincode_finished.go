@@ -17,10 +17,9 @@ limitations under the License.
 package main
 
 import (
-	"errors"
+	"encoding/json"
 	"fmt"
 	"strconv"
-	"encoding/json"
 	"strings"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
@@ -30,42 +29,99 @@ import (
 type SimpleChaincode struct {
 }
 
-type SKATEmployee struct{
-        CPRNum int `json:"CPRNum"`
-        VirkNum int `json:"VirkNum"`
-        CPRNavn string `json:"CPRNavn"`
-        DateOfWork string `json:"DOW"`
-		NoOfHours int `json:"NoOfHours"`
-		Comment string `json:"Comments"`
-}
+type SKATEmployee struct {
+	CPRNum     int     `json:"CPRNum"`
+	VirkNum    int     `json:"VirkNum"`
+	CPRNavn    string  `json:"CPRNavn"`
+	DateOfWork string  `json:"DOW"`
+	NoOfHours  float64 `json:"NoOfHours"`
+	Comment    string  `json:"Comments"`
 
-var employeeLogBog map[string]SKATEmployee
+	// SchemaVersion is the layout the record was stored with, see logbog_schema.go
+	SchemaVersion int `json:"SchemaVersion,omitempty"`
 
-type SKATEmployeeRepository struct{
-	EmployeeList []SKATEmployee `json:"employee_list"`
+	// StartTime and EndTime of the shift, when logged; see logbog_shift.go
+	StartTime string `json:"StartTime,omitempty"`
+	EndTime   string `json:"EndTime,omitempty"`
+
+	// Set in a pseudonymising deployment, which leaves CPRNum and CPRNavn empty in state
+	CPRHash    string `json:"CPRHash,omitempty"`
+	CPRNumEnc  string `json:"CPRNumEnc,omitempty"`
+	CPRNavnEnc string `json:"CPRNavnEnc,omitempty"`
+
+	// Retracted entries are kept for the record but left out of searches by default
+	Retracted     bool   `json:"Retracted,omitempty"`
+	RetractReason string `json:"RetractReason,omitempty"`
+	RetractedAt   string `json:"RetractedAt,omitempty"`
+
+	// Erased entries were anonymised by eraseSubject; CPRHash then holds the anonymous subject
+	Erased bool `json:"Erased,omitempty"`
+
+	// RuleViolations lists the working-time rules the entry broke when it was written in flag mode
+	RuleViolations []string `json:"RuleViolations,omitempty"`
+
+	// Version numbers the newest link in the entry's history chain
+	Version int `json:"Version,omitempty"`
 }
 
-type searchedEmployees struct{
-	SearchedEmployeeList []SKATEmployee `json:"searched_employee_list"`
+// SKATEmployeeRepository is the legacy single-key list of every entry. It is
+// only read by migrateLogBogRepository; entries now live under their own keys.
+type SKATEmployeeRepository struct {
+	EmployeeList []SKATEmployee `json:"employee_list"`
 }
 
-func main() {
-	err := shim.Start(new(SimpleChaincode))
+// Init resets all the things. An optional second argument carries the LogBog config as JSON.
+func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
+	err := validateArgs(args, logBogArgSpecs["init"])
 	if err != nil {
-		fmt.Printf("Error starting Simple chaincode: %s", err)
+		return nil, err
 	}
-}
 
-// Init resets all the things
-func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
-	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 1")
+	config := defaultLogBogConfig()
+	if len(args) == 2 {
+		config, err = parseLogBogConfig(args[1])
+		if err != nil {
+			return nil, newFieldError("config", "config "+err.Error())
+		}
+	}
+	current, err := getLogBogConfig(stub)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := hasLogBogEntries(stub)
+	if err != nil {
+		return nil, err
+	}
+	if stored && config.Pseudonymise != current.Pseudonymise {
+		return nil, newFieldError("config", "config cannot switch pseudonymise once entries are stored")
+	}
+	// stored entries keep the key they were written with; otherwise the caller's key, if any, becomes the one
+	config.KeyCheck = ""
+	if config.Pseudonymise {
+		if stored {
+			config.KeyCheck = current.KeyCheck
+		}
+		keys, err := callerLogBogKeys(stub)
+		if err != nil {
+			return nil, err
+		}
+		if keys != nil {
+			err = keys.checkAgainst(config)
+			if err != nil {
+				return nil, err
+			}
+			config.KeyCheck = keys.Check
+		}
 	}
 
-	err := stub.PutState("hello_Block", []byte(args[0]))
+	_, err = putVersionedValue(stub, "hello_Block", args[0], -1)
 	if err != nil {
 		return nil, err
 	}
+	err = putLogBogConfig(stub, config)
+	if err != nil {
+		return nil, newStateError(logBogConfigKey, err)
+	}
 
 	return nil, nil
 }
@@ -74,398 +130,658 @@ func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface, function string
 func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
 	fmt.Println("invoke is running " + function)
 
+	if specs, ok := logBogArgSpecs[function]; ok {
+		err := validateArgs(args, specs)
+		if err != nil {
+			return nil, err
+		}
+		err = checkAccess(stub, function, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Handle different functions
 	if function == "init" {
 		return t.Init(stub, "init", args)
 	} else if function == "write" {
 		return t.write(stub, args)
-	} else if  function == "addToLogBog" {
+	} else if function == "addToLogBog" {
 		return t.addSKATEmployee(stub, args)
-	} else if  function == "updateLogBog" {
+	} else if function == "upsertLogBog" {
+		return t.upsertSKATEmployee(stub, args)
+	} else if function == "bulkAddToLogBog" {
+		return t.bulkAddSKATEmployees(stub, args)
+	} else if function == "updateLogBog" {
 		return t.updateSKATEmployee(stub, args)
+	} else if function == "retractLogBog" {
+		return t.retractSKATEmployee(stub, args)
+	} else if function == "migrateLogBogRepository" {
+		return t.migrateLogBogRepository(stub, args)
+	} else if function == "migrateLogBog" {
+		return t.migrateLogBog(stub, args)
+	} else if function == "eraseSubject" {
+		return t.eraseSubject(stub, args)
+	} else if function == "bindVirkNum" {
+		return t.bindVirkNum(stub, args)
+	} else if function == "unbindVirkNum" {
+		return t.unbindVirkNum(stub, args)
 	}
 	fmt.Println("invoke did not find func: " + function)
 
-	return nil, errors.New("Received unknown function invocation: " + function)
+	return nil, &logBogError{Code: errCodeUnknownFunction, Message: "Received unknown function invocation: " + function}
 }
 
 // Query is our entry point for queries
 func (t *SimpleChaincode) Query(stub shim.ChaincodeStubInterface, function string, args []string) ([]byte, error) {
 	fmt.Println("query is running " + function)
 
+	if specs, ok := logBogArgSpecs[function]; ok {
+		err := validateArgs(args, specs)
+		if err != nil {
+			return nil, err
+		}
+		err = checkAccess(stub, function, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Handle different functions
 	if function == "read" { //read a variable
 		return t.read(stub, args)
+	} else if function == "readVersion" {
+		return t.readVersion(stub, args)
 	} else if function == "searchLogBog" {
-		return t.searchSKATEmployee(stub,args)
+		return t.searchSKATEmployee(stub, args)
+	} else if function == "reportHours" {
+		return t.reportHours(stub, args)
+	} else if function == "exportLogBog" {
+		return t.exportLogBog(stub, args)
+	} else if function == "historyLogBog" {
+		return t.historySKATEmployee(stub, args)
+	} else if function == "getVirkBinding" {
+		return t.queryVirkBinding(stub, args)
+	} else if function == "checkBulkAddToLogBog" {
+		return t.checkBulkAddSKATEmployees(stub, args)
 	}
 	fmt.Println("query did not find func: " + function)
 
-	return nil, errors.New("Received unknown function query: " + function)
+	return nil, &logBogError{Code: errCodeUnknownFunction, Message: "Received unknown function query: " + function}
 }
 
-// write - invoke function to write key/value pair
+// write - invoke function to write key/value pair, only if the key is still at expectedVersion when one is given
 func (t *SimpleChaincode) write(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
 	var key, value string
 	var err error
 	fmt.Println("running write()")
 
-	if len(args) != 2 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 2. name of the key and value to set")
-	}
-
+	//   0        1                2
+	// "key", "value", "expectedVersion (optional)"
 	key = args[0] //rename for funsies
 	value = args[1]
-	err = stub.PutState(key, []byte(value)) //write the variable into the chaincode state
+	expectedVersion := -1
+	if len(args) > 2 {
+		expectedVersion, _ = parseExpectedVersion(args[2])
+	}
+	written, err := putVersionedValue(stub, key, value, expectedVersion) //write the variable into the chaincode state
 	if err != nil {
 		return nil, err
 	}
-	return nil, nil
+	return json.Marshal(written)
 }
 
 // read - query function to read key/value pair
 func (t *SimpleChaincode) read(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	var key, jsonResp string
-	var err error
-
-	if len(args) != 1 {
-		return nil, errors.New("Incorrect number of arguments. Expecting name of the key to query")
-	}
+	var key string
 
 	key = args[0]
 	valAsbytes, err := stub.GetState(key)
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + key + "\"}"
-		return nil, errors.New(jsonResp)
+		return nil, newStateError(key, err)
 	}
 
 	return valAsbytes, nil
 }
 
+// readVersion - query function to read key/value pair along with the version to pass back to write
+func (t *SimpleChaincode) readVersion(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	current, err := getVersionedValue(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(current)
+}
+
 // ============================================================================================================================
-// Init Employee - create a new Employee, store into chaincode state
+// Init Employee - create a new Employee, store into chaincode state; fails if the entry already exists
 // ============================================================================================================================
 func (t *SimpleChaincode) addSKATEmployee(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	var err error
-	var key,jsonResp string
-	
-	//   0       1       2     3
-	// "asdf", "blue", "35", "bob"
-	if len(args) != 5 {
-		return nil, errors.New("Incorrect number of arguments. Expecting 5")
-	}
+	return t.storeSKATEmployee(stub, args, false)
+}
 
-	//input sanitation
+// ============================================================================================================================
+// Upsert Employee - create or replace an Employee entry
+// ============================================================================================================================
+func (t *SimpleChaincode) upsertSKATEmployee(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	return t.storeSKATEmployee(stub, args, true)
+}
+
+func (t *SimpleChaincode) storeSKATEmployee(stub shim.ChaincodeStubInterface, args []string, replace bool) ([]byte, error) {
+	//     0         1          2          3             4                     5                      6                      7
+	// "CPRNum", "VirkNum", "CPRNavn", "DateOfWork", "NoOfHours", "Comment (optional)", "StartTime (optional)", "EndTime (optional)"
 	fmt.Println("- start init SKATEmployee")
-	if len(args[0]) <= 0 {
-		return nil, errors.New("1st argument must be a non-empty string")
-	}
-	if len(args[1]) <= 0 {
-		return nil, errors.New("2nd argument must be a non-empty string")
-	}
-	if len(args[2]) <= 0 {
-		return nil, errors.New("3rd argument must be a non-empty string")
+	Employee, err := parseSKATEmployee(stub, args)
+	if err != nil {
+		return nil, err
 	}
-	if len(args[3]) <= 0 {
-		return nil, errors.New("4th argument must be a non-empty string")
+	Employee, change, err := t.putSKATEmployee(stub, Employee, replace)
+	if err != nil {
+		return nil, err
 	}
-	Employee := SKATEmployee{}
-	Employee.CPRNum, err = strconv.Atoi(args[0])
+	err = emitLogBogEvent(stub, change)
 	if err != nil {
-		return nil, errors.New("3rd argument must be a numeric string")
+		return nil, err
 	}
-	Employee.VirkNum, err = strconv.Atoi(args[1])
-	Employee.CPRNavn = strings.ToLower(args[2])
-	Employee.DateOfWork = strings.ToLower(args[3])
-	Employee.NoOfHours, err = strconv.Atoi(args[4])
+	fmt.Println("- end add Employee")
+	return json.Marshal(openLogBogEntry(stub, Employee))
+}
 
-	if len(args) == 6 {
-	Employee.Comment = args[5]
-  	}
-	fmt.Println("adding employee @ " + strconv.Itoa(Employee.CPRNum) + ", " + strconv.Itoa(Employee.VirkNum) + ", " + Employee.CPRNavn);
-	fmt.Println("- end add Employee 1")
-	jsonAsBytes, _ := json.Marshal(Employee)
+// putSKATEmployee stores a parsed entry, replacing an existing one only if
+// replace is set, and returns it as stored with the change to report.
+func (t *SimpleChaincode) putSKATEmployee(stub shim.ChaincodeStubInterface, Employee SKATEmployee, replace bool) (SKATEmployee, logBogChange, error) {
+	var existing SKATEmployee
+	var change logBogChange
 
+	Employee, err := sealLogBogEntry(stub, Employee)
 	if err != nil {
-		return jsonAsBytes, err
+		return Employee, change, err
 	}
-	
-	key = strconv.Itoa(Employee.CPRNum) + "_" + strconv.Itoa(Employee.VirkNum) + "_" + Employee.DateOfWork
-	err = stub.PutState(key, jsonAsBytes)	//store employee with id as key
+
+	operation := opAdd
+	key := logBogEntryKey(Employee)
+	fmt.Println("adding employee @ " + key)
+	existingJsonAsBytes, err := stub.GetState(key)
 	if err != nil {
-		return nil, err
-	}	
-	//var  employeeRepository SKATEmployeeRepository
-	//employeeRepository.EmployeeList = append(employeeRepository.EmployeeList,Employee)
-	//SKATEmployeeRepository.EmployeeList = append(SKATEmployeeRepository.EmployeeList,Employee)
-	repositoryJsonAsBytes, err := stub.GetState("SKATEmployeeRepository")
+		return Employee, change, newStateError(key, err)
+	}
+	if existingJsonAsBytes != nil {
+		if !replace {
+			return Employee, change, newLogBogError(errCodeAlreadyExists, key, "Employee log already exists for "+maskedEntryKey(Employee)+", use upsertLogBog to replace it")
+		}
+		existing, err = decodeLogBogEntry(key, existingJsonAsBytes)
+		if err != nil {
+			return Employee, change, err
+		}
+		operation = opUpsert
+		Employee.Version = existing.Version
+	} else {
+		Employee.Version, err = lastLogBogVersion(stub, Employee)
+		if err != nil {
+			return Employee, change, err
+		}
+	}
+	Employee.Version++
+	Employee.RuleViolations, err = applyWorkingTimeRules(stub, Employee)
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + "SKATEmployeeRepository" + "\"}"
-		return nil, errors.New(jsonResp)
+		return Employee, change, err
 	}
-	var employeeRepository SKATEmployeeRepository
-	json.Unmarshal(repositoryJsonAsBytes, &employeeRepository)	
 
+	_, err = t.updateEmployeeRepository(stub, Employee, operation)
+	if err != nil {
+		return Employee, change, err
+	}
+	return Employee, newLogBogChange(stub, operation, existing, Employee), nil
+}
+
+// parseSKATEmployee builds an entry from addToLogBog/upsertLogBog arguments
+// already checked against storeLogBogArgs.
+func parseSKATEmployee(stub shim.ChaincodeStubInterface, args []string) (SKATEmployee, error) {
+	var err error
+	Employee := SKATEmployee{}
 
-   	employeeRepository.EmployeeList = append(employeeRepository.EmployeeList,Employee)
-	//update Employee Repository
-	updatedRepositoryJsonAsBytes, _  := json.Marshal(employeeRepository)
-	err = stub.PutState("SKATEmployeeRepository", updatedRepositoryJsonAsBytes)	//store employee with id as key
+	Employee.CPRNum, err = parseCPR(args[0])
 	if err != nil {
-		return nil, err
-	}		
-	fmt.Println("len(employeeRepository) in Add:"+ strconv.Itoa(len(employeeRepository.EmployeeList)));	
-	fmt.Println("employeeRepository:", employeeRepository.EmployeeList[0:])					
-	fmt.Println("- end add Employee 2")
-	return jsonAsBytes, nil
+		return Employee, newFieldError("CPRNum", "CPRNum "+err.Error())
+	}
+	Employee.VirkNum, err = parseVirkNum(args[1])
+	if err != nil {
+		return Employee, newFieldError("VirkNum", "VirkNum "+err.Error())
+	}
+	Employee.CPRNavn = strings.ToLower(args[2])
+	dateOfWork, err := parseDateOfWork(args[3])
+	if err != nil {
+		return Employee, newFieldError("DateOfWork", "DateOfWork "+err.Error())
+	}
+	err = checkDateOfWork(stub, dateOfWork)
+	if err != nil {
+		return Employee, err
+	}
+	Employee.DateOfWork = dateOfWork.Format(dateOfWorkFormat)
+	if len(args[4]) > 0 {
+		Employee.NoOfHours, err = parseHours(args[4])
+		if err != nil {
+			return Employee, newFieldError("NoOfHours", "NoOfHours "+err.Error())
+		}
+	}
+	if len(args) > 5 {
+		Employee.Comment = args[5]
+	}
+	if len(args) > 6 {
+		Employee.StartTime = args[6]
+	}
+	if len(args) > 7 {
+		Employee.EndTime = args[7]
+	}
+	err = checkShift(&Employee, len(args[4]) == 0)
+	if err != nil {
+		return Employee, err
+	}
+	return Employee, nil
 }
 
 // ============================================================================================================================
-// Search Employees
+// Search Employees - range-scan the entry keys by CPRNum or the VirkNum index, optionally within a DateOfWork window.
+// With a pageSize the matches are returned a page at a time as {records, nextToken, total}
 // ============================================================================================================================
 func (t *SimpleChaincode) searchSKATEmployee(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-	var cprNo, virkNo, cprForEmployee , virkForEmployee string
-	var jsonResp  string
+	var cprNo, virkNo, from, to, pageToken string
+	var includeRetracted bool
 	var err error
-	var foundEmployee bool
-	foundEmployee = false
-	//var SearchedEmployeeList []SKATEmployee 
 
 	SearchedEmployeeList := []SKATEmployee{}
-	
-	if len(args) != 2 {
-		return nil, errors.New("Incorrect number of arguments. Expecting CPRNum , VirkNum as input")
-	}
 
-	cprNo = args[0]
-	virkNo = args[1]
-  	
-	repositoryJsonAsBytes, err := stub.GetState("SKATEmployeeRepository")
+	//   0         1          2                 3                   4                              5                       6
+	// "CPRNum", "VirkNum", "from (optional)", "to (optional)", "includeRetracted (optional)", "pageSize (optional)", "pageToken (optional)"
+	cprNo, err = normaliseCPR(args[0])
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + "SKATEmployeeRepository" + "\"}"
-		return nil, errors.New(jsonResp)
+		return nil, err
 	}
-	var employeeRepository SKATEmployeeRepository
-	json.Unmarshal(repositoryJsonAsBytes, &employeeRepository)	
-	
-	fmt.Println("len(employeeRepository) in search:"+ strconv.Itoa(len(employeeRepository.EmployeeList)));
-	/*for i := range employeeRepository.EmployeeList{
-		cprForEmployee = strconv.Itoa(employeeRepository.EmployeeList[i].CPRNum)
-		fmt.Println("matching record:"+ strconv.Itoa(i))
-		//fmt.Println("looking at " + strconv.FormatInt(trades.OpenTrades[i].Timestamp, 10) + " for " + strconv.FormatInt(timestamp, 10))
-		if 	(strings.Contains(cprForEmployee,cprNo) || strings.Contains(virkForEmployee,virkNo)){
-			fmt.Println("found the employee 1");
-			SearchedEmployeeList = append(SearchedEmployeeList,employeeRepository.EmployeeList[i])
-			fmt.Println("found the employee 2"+ SearchedEmployeeList[i].CPRNavn );
-			fmt.Println("SearchedEmployeeList[:"+ strconv.Itoa(i) +"] ==", SearchedEmployeeList[i])		
+	cprNo, err = logBogCPRKey(stub, cprNo)
+	if err != nil {
+		return nil, err
+	}
+	virkNo, err = normaliseVirkNum(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 2 {
+		from = args[2]
+	}
+	if len(args) > 3 {
+		to = args[3]
+	}
+	if len(args) > 4 && len(args[4]) > 0 {
+		includeRetracted, _ = strconv.ParseBool(args[4])
+	}
+	if len(args) > 6 {
+		pageToken = args[6]
+	}
+	pageSize := 0
+	if len(args) > 5 && len(args[5]) > 0 {
+		pageSize, _ = parsePageSize(args[5])
+	} else if len(pageToken) > 0 {
+		return nil, newFieldError("pageToken", "pageToken needs a pageSize")
+	}
+	window, err := newDateWindow(from, to)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("searching cpr Number:" + cprNo + " Virk Num:" + virkNo + " from:" + from + " to:" + to)
+
+	// filter on the stored fields; only the records returned are decrypted
+	match := func(skatEmployee SKATEmployee) bool {
+		if skatEmployee.Retracted && !includeRetracted {
+			return false
 		}
-	}*/
-	//jsonAsBytes, _ := json.Marshal(len(SearchedEmployeeList[:]))
-	fmt.Println("searching cpr Number:"+ cprNo + " Virk Num:"+ virkNo)
-	fmt.Println("len(cprNo):"+ strconv.Itoa(len(cprNo)))
-	fmt.Println("len(virkNo):"+ strconv.Itoa(len(virkNo)))
-	fmt.Println("len(cprNo)==0:"+ strconv.FormatBool(len(cprNo)==0))
-	fmt.Println("len(virkNo)==0:"+ strconv.FormatBool(len(virkNo)==0))
-	result := "["
-	
-	for _,skatEmployee := range employeeRepository.EmployeeList{
-		foundEmployee = false
-		cprForEmployee = strconv.Itoa(skatEmployee.CPRNum)
-		virkForEmployee= strconv.Itoa(skatEmployee.VirkNum)
-		fmt.Println("matching record")
-		if ( len(cprNo) >0 && len (virkNo) >0 ){
-			fmt.Println("matching both")
-			if (cprForEmployee == cprNo && virkForEmployee == virkNo ){
-				foundEmployee=true
-				fmt.Println("found both")
-				}
-		} else if ((len(cprNo) > 0) && (len(virkNo)==0)) {
-			fmt.Println("matching cprNo")
-			if cprForEmployee == cprNo {
-				foundEmployee=true
-				fmt.Println("found cprNo")
-				}
-		} else if ((len(virkNo) > 0)  && (len(cprNo)==0)) {
-			fmt.Println("matching virkNo")
-			if virkForEmployee == virkNo {
-				foundEmployee=true
-				fmt.Println("found virkNo")
-				}
+		return window.contains(skatEmployee.DateOfWork)
+	}
+	scan, ok := planLogBogScan(cprNo, virkNo, window)
+	if !ok && pageSize > 0 {
+		return json.Marshal(logBogPage{Records: []SKATEmployee{}})
+	} else if !ok {
+		return json.Marshal(SearchedEmployeeList)
+	}
+
+	if pageSize > 0 {
+		page, err := pageLogBog(stub, scan, match, pageSize, pageToken)
+		if err != nil {
+			return nil, err
 		}
-		fmt.Println("foundEmployee : " + strconv.FormatBool(foundEmployee))
-		//fmt.Println("looking at " + strconv.FormatInt(trades.OpenTrades[i].Timestamp, 10) + " for " + strconv.FormatInt(timestamp, 10))
-		if 	(foundEmployee == true){
-			SearchedEmployeeList = append(SearchedEmployeeList,skatEmployee)
-			fmt.Println("found the employee-"+ skatEmployee.CPRNavn );
-			temp, err := json.Marshal(skatEmployee)
-			if err == nil {
-			result += string(temp) + ","
-			}
+		for i := range page.Records {
+			page.Records[i] = openLogBogEntry(stub, page.Records[i])
 		}
+		return json.Marshal(page)
 	}
-	if len(result) == 1 {
-		result = "[]"
-	} else {
-		result = result[:len(result)-1] + "]"
-	}	
-	fmt.Println("SearchedEmployeeList:", SearchedEmployeeList[0:])
-/*	var interfaceSlice []interface{} = make([]interface{}, len(SearchedEmployeeList))
-	for i, skatEmployee := range SearchedEmployeeList {
-    interfaceSlice[i] = skatEmployee
-	jsonAsBytes, _ := json.Marshal(interfaceSlice)
-	}*/
-		
-	return []byte(result), nil
 
+	candidates, err := readLogBogScan(stub, scan)
+	if err != nil {
+		return nil, err
+	}
+	for _, skatEmployee := range candidates {
+		if match(skatEmployee) {
+			SearchedEmployeeList = append(SearchedEmployeeList, openLogBogEntry(stub, skatEmployee))
+		}
+	}
+	fmt.Println("len(SearchedEmployeeList):" + strconv.Itoa(len(SearchedEmployeeList)))
+	return json.Marshal(SearchedEmployeeList)
 }
+
+// findLogBogEntries decodes every entry planLogBogScan finds. Nothing is matched when the CPRNum key
+// part, VirkNum and window are all left open.
+func findLogBogEntries(stub shim.ChaincodeStubInterface, cprNo, virkNo string, window dateWindow) ([]SKATEmployee, error) {
+	scan, ok := planLogBogScan(cprNo, virkNo, window)
+	if !ok {
+		return []SKATEmployee{}, nil
+	}
+	return readLogBogScan(stub, scan)
+}
+
+// planLogBogScan picks the cheapest scan for a CPRNum key part and VirkNum, either of which may be
+// empty, falling back to the DateOfWork index; ok is false when there is nothing to scan by.
+func planLogBogScan(cprNo, virkNo string, window dateWindow) (logBogScan, bool) {
+	if len(cprNo) > 0 && len(virkNo) > 0 {
+		fmt.Println("matching both")
+		return entryScan(logBogEntryPrefix + cprNo + "_" + virkNo + "_"), true
+	} else if len(cprNo) > 0 {
+		fmt.Println("matching cprNo")
+		return entryScan(logBogEntryPrefix + cprNo + "_"), true
+	} else if len(virkNo) > 0 {
+		fmt.Println("matching virkNo")
+		return indexScan(logBogVirkIndexPrefix + virkNo + "_"), true
+	} else if !window.isOpen() {
+		fmt.Println("matching dateOfWork")
+		return dateIndexScan(window), true
+	}
+	return logBogScan{}, false
+}
+
 // ============================================================================================================================
-// Update Employee - Update Employee with Comments, store into chaincode state
+// Update Employee - patch NoOfHours, CPRNavn, Comment and/or the shift times of an existing entry, store into chaincode state
 // ============================================================================================================================
 func (t *SimpleChaincode) updateSKATEmployee(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
-var cprNum,virkNum, dateOfWork,comment, key,jsonResp string
-var employee SKATEmployee
-var err error
+	var cprNum, virkNum, dateOfWork string
+	var before, after SKATEmployee
+	var err error
 
-if len(args) != 4 {
-		return nil, errors.New("Incorrect number of arguments. Expecting CPRNum , Comments as input")
+	//     0         1            2                         3
+	// "CPRNum", "VirkNum", "DateOfWork", "{\"NoOfHours\":7,\"CPRNavn\":..,\"Comment\":..} or Comment"
+	cprNum = args[0]
+	virkNum = args[1]
+	dateOfWork = args[2]
+	patch, err := parseLogBogPatch(args[3])
+	if err != nil {
+		return nil, err
+	}
+	before, err = t.getEmployeeLog(stub, cprNum, virkNum, dateOfWork)
+	if err != nil {
+		return nil, err
+	}
+	if before.Retracted {
+		return nil, newLogBogError(errCodeRetracted, logBogEntryKey(before), "Employee log was retracted and cannot be updated: "+before.RetractReason)
+	}
+	fmt.Println("Updating Employee -" + logBogEntryKey(before))
+	after = patch.apply(before)
+	err = checkShift(&after, patch.derivesHours())
+	if err != nil {
+		return nil, err
+	}
+	after.Version++
+	after.RuleViolations, err = applyWorkingTimeRules(stub, after)
+	if err != nil {
+		return nil, err
 	}
 
-cprNum=args[0]
-virkNum=args[1]
-dateOfWork=args[2]
-comment=args[3]
-employee , err= t.getEmployeeLog(stub,cprNum,virkNum,dateOfWork)
-fmt.Println("Updating Employee -"+ strconv.Itoa(employee.CPRNum) +" "+ strconv.Itoa(employee.VirkNum)+" "+ employee.DateOfWork)
-employee.Comment = comment
-key = strconv.Itoa(employee.CPRNum) + "_" + strconv.Itoa(employee.VirkNum) + "_" + employee.DateOfWork
-jsonAsBytes, _ := json.Marshal(employee)
-err = stub.PutState(key, jsonAsBytes) //write the variable into the chaincode state
-fmt.Println("Updated Employee with key-"+key)
-
-if err != nil {
-		jsonResp = "{\"Error\":\"Failed to update Employee" + "\"}"
-		return nil, errors.New(jsonResp)
+	_, err = t.updateEmployeeRepository(stub, after, opUpdate)
+	if err != nil {
+		return nil, err
 	}
-_, err = t.updateEmployeeRepository(stub,employee)
-if err != nil {
-		jsonResp = "{\"Error\":\"Failed to update SKAT Repository" + "\"}"
-		return nil, errors.New(jsonResp)
+	err = emitLogBogEvent(stub, newLogBogChange(stub, opUpdate, before, after))
+	if err != nil {
+		return nil, err
 	}
 
-return jsonAsBytes,nil
+	return json.Marshal(map[string]SKATEmployee{"before": openLogBogEntry(stub, before), "after": openLogBogEntry(stub, after)})
 }
 
-//==================================================================================================================================
-//Append to EmployeeRepository
-//===================================================================================================================================
+// ============================================================================================================================
+// Retract Employee - mark an entry retracted with a reason, or delete it outright when an administrator asks for "hard"
+// ============================================================================================================================
+const (
+	retractSoft = "soft"
+	retractHard = "hard"
+)
 
-func (t *SimpleChaincode) appendtoEmployeeRepository(stub shim.ChaincodeStubInterface,  key string,employee SKATEmployee) (bool, error){
+func (t *SimpleChaincode) retractSKATEmployee(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var employee SKATEmployee
+	var err error
 
-var jsonResp string 
-repositoryJsonAsBytes, err := stub.GetState("SKATEmployeeRepository")
+	//     0         1            2           3                 4
+	// "CPRNum", "VirkNum", "DateOfWork", "reason", "soft (default) or hard"
+	mode := retractSoft
+	if len(args) > 4 && len(args[4]) > 0 {
+		mode = args[4]
+	}
+	if mode == retractHard {
+		err = requireAdmin(stub, "Hard delete")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	employee, err = t.getEmployeeLog(stub, args[0], args[1], args[2])
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + "SKATEmployeeRepository" + "\"}"
-		return false, errors.New(jsonResp)
+		return nil, err
+	}
+	fmt.Println("Retracting Employee (" + mode + ") -" + logBogEntryKey(employee))
+
+	if mode == retractHard {
+		err = delLogBogEntry(stub, employee)
+		if err != nil {
+			return nil, err
+		}
+		employee.Version++
+		err = appendLogBogVersion(stub, opDelete, employee)
+		if err != nil {
+			return nil, err
+		}
+		err = emitLogBogEvent(stub, newLogBogChange(stub, opDelete, employee, SKATEmployee{Version: employee.Version}))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(openLogBogEntry(stub, employee))
 	}
-	var employeeRepository SKATEmployeeRepository
-	json.Unmarshal(repositoryJsonAsBytes, &employeeRepository)	
 
-	employeeRepository.EmployeeList = append(employeeRepository.EmployeeList,employee)
-	//update Employee Repository
-	updatedRepositoryJsonAsBytes, _  := json.Marshal(employeeRepository)
-	err = stub.PutState("SKATEmployeeRepository", updatedRepositoryJsonAsBytes)	//store employee with id as key
+	if employee.Retracted {
+		return nil, newLogBogError(errCodeRetracted, logBogEntryKey(employee), "Employee log is already retracted: "+employee.RetractReason)
+	}
+	before := employee
+	employee.Retracted = true
+	employee.RetractReason = args[3]
+	employee.RetractedAt = txTimeString(stub)
+	employee.Version++
+
+	_, err = t.updateEmployeeRepository(stub, employee, opRetract)
 	if err != nil {
-		return false, err
-	}		
-	return true, nil
+		return nil, err
+	}
+	err = emitLogBogEvent(stub, newLogBogChange(stub, opRetract, before, employee))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(openLogBogEntry(stub, employee))
 }
+
 // ============================================================================================================================
-// Get single Employee
+// Get single Employee - the entry for CPRNum, VirkNum and DateOfWork as stored, or a NOT_FOUND logBogError
 // ============================================================================================================================
-func (t *SimpleChaincode) getEmployee(stub shim.ChaincodeStubInterface, cprNum string) (SKATEmployee, error) {
+func (t *SimpleChaincode) getEmployeeLog(stub shim.ChaincodeStubInterface, cprNum string, VirkNum string, DateOfWork string) (SKATEmployee, error) {
 
-	var jsonResp, cprForEmployee  string
 	var employee SKATEmployee
-	
-	repositoryJsonAsBytes, err := stub.GetState("SKATEmployeeRepository")
+	var err error
+
+	employee.CPRNum, err = parseCPR(cprNum)
 	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + "SKATEmployeeRepository" + "\"}"
-		return employee, errors.New(jsonResp)
+		return employee, newFieldError("CPRNum", "CPRNum "+err.Error())
 	}
-	var employeeRepository SKATEmployeeRepository
-	json.Unmarshal(repositoryJsonAsBytes, &employeeRepository)	
-	
-	for _,skatEmployee := range employeeRepository.EmployeeList{
-		cprForEmployee = strconv.Itoa(skatEmployee.CPRNum)
-		
-		fmt.Println("matching record")
-		//fmt.Println("looking at " + strconv.FormatInt(trades.OpenTrades[i].Timestamp, 10) + " for " + strconv.FormatInt(timestamp, 10))
-		if 	(strings.Contains(cprForEmployee,cprNum)){
-			fmt.Println("found the employee 1" + skatEmployee.CPRNavn );
-			employee=skatEmployee
-			}
-		
-		}
-			return employee, nil
-	
+	employee.VirkNum, err = parseVirkNum(VirkNum)
+	if err != nil {
+		return employee, newFieldError("VirkNum", "VirkNum "+err.Error())
 	}
-	// ============================================================================================================================
-// Get single Employee
-// ============================================================================================================================
-func (t *SimpleChaincode) getEmployeeLog(stub shim.ChaincodeStubInterface, cprNum string,VirkNum string ,DateOfWork string) (SKATEmployee, error) {
-
-	var employee SKATEmployee
-	var key string
-	key=cprNum+"_"+VirkNum+"_"+DateOfWork
+	employee.DateOfWork, err = normaliseDateOfWork(DateOfWork)
+	if err != nil {
+		return employee, newFieldError("DateOfWork", "DateOfWork "+err.Error())
+	}
+	employee, err = sealLogBogEntry(stub, employee)
+	if err != nil {
+		return employee, err
+	}
+	key := logBogEntryKey(employee)
 	bytes, err := stub.GetState(key)
-
 	if err != nil {
-			fmt.Printf("getEmployeeLog: Failed to find employee Log: %s", err); 
-			return employee, errors.New("getEmployeeLog: Failed to find employee Log with these details: %s"+ cprNum+" "+VirkNum+" "+DateOfWork) 
-		}
+		fmt.Printf("getEmployeeLog: Failed to find employee Log: %s", err)
+		return employee, newStateError(key, err)
+	}
+	if bytes == nil {
+		return employee, newLogBogError(errCodeNotFound, key, "No employee log found for "+cprNum+" "+VirkNum+" "+employee.DateOfWork)
+	}
+
+	return decodeLogBogEntry(key, bytes)
+}
 
-	err = json.Unmarshal(bytes, &employee);
+//==================================================================================================================================
+// Store Employee - write the entry under its own key together with its VirkNum and DateOfWork index keys,
+// and append it to the entry's history as the outcome of operation. The caller sets the new Version;
+// CPRNum and CPRNavn are sealed here if the deployment pseudonymises.
+//===================================================================================================================================
 
-	return employee, nil
-	
+func (t *SimpleChaincode) updateEmployeeRepository(stub shim.ChaincodeStubInterface, employee SKATEmployee, operation string) (bool, error) {
+	var err error
+	employee.DateOfWork, err = normaliseDateOfWork(employee.DateOfWork)
+	if err != nil {
+		return false, newFieldError("DateOfWork", "DateOfWork "+err.Error())
+	}
+	employee, err = sealLogBogEntry(stub, employee)
+	if err != nil {
+		return false, err
+	}
+	err = putLogBogEntry(stub, employee)
+	if err != nil {
+		return false, err
+	}
+	err = appendLogBogVersion(stub, operation, employee)
+	if err != nil {
+		return false, err
 	}
+	return true, nil
+}
+
+// repositoryMigration is the outcome of migrateLogBogRepository. Skipped lists
+// the legacy records left in the repository blob: those whose entry key is
+// already taken, and those whose DateOfWork cannot be canonicalised.
+type repositoryMigration struct {
+	Migrated int             `json:"migrated"`
+	Skipped  []skippedRecord `json:"skipped"`
+}
+
+// skippedRecord names a legacy record migrateLogBogRepository left behind by
+// its CPRNum, VirkNum and DateOfWork as stored in the blob, so it can be fixed
+// with updateLogBog or retractLogBog and the migration run again. EntryKey is
+// the key already taken, if that is why it was skipped.
+type skippedRecord struct {
+	CPRNum     int    `json:"CPRNum"`
+	VirkNum    int    `json:"VirkNum"`
+	DateOfWork string `json:"DateOfWork"`
+	LegacyKey  string `json:"legacyKey"`
+	EntryKey   string `json:"entryKey,omitempty"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
 
 //==================================================================================================================================
-//Replace Record in EmployeeRepository
+// Migrate Repository - explode the legacy SKATEmployeeRepository blob into per-entry and index keys
 //===================================================================================================================================
 
-func (t *SimpleChaincode) updateEmployeeRepository(stub shim.ChaincodeStubInterface, employee SKATEmployee) (bool, error){
-
-var jsonResp,cprForEmployee,virkNumForEmployee, dateOfWorkForEmployee string 
-repositoryJsonAsBytes, err := stub.GetState("SKATEmployeeRepository")
-	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + "SKATEmployeeRepository" + "\"}"
-		return false, errors.New(jsonResp)
-	}
-	var initialEmployeeRepository,finalEmployeeRepository SKATEmployeeRepository
-	json.Unmarshal(repositoryJsonAsBytes, &initialEmployeeRepository)	
-	
-	for _,skatEmployee := range initialEmployeeRepository.EmployeeList{
-		cprForEmployee = strconv.Itoa(skatEmployee.CPRNum)
-		virkNumForEmployee= strconv.Itoa(skatEmployee.VirkNum)
-		dateOfWorkForEmployee=skatEmployee.DateOfWork
-		fmt.Println("matching record")
-		//fmt.Println("looking at " + strconv.FormatInt(trades.OpenTrades[i].Timestamp, 10) + " for " + strconv.FormatInt(timestamp, 10))
-		if 	((cprForEmployee == strconv.Itoa(employee.CPRNum)) && (virkNumForEmployee == strconv.Itoa(employee.VirkNum)) && (dateOfWorkForEmployee == employee.DateOfWork) ){
-			fmt.Println("found the employee 1" + skatEmployee.CPRNavn );
-			//do not copy
-			} else {//copy
-				finalEmployeeRepository.EmployeeList = append(finalEmployeeRepository.EmployeeList,skatEmployee)	
-			}
-		
+func (t *SimpleChaincode) migrateLogBogRepository(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	repositoryJsonAsBytes, err := stub.GetState(logBogRepositoryKey)
+	if err != nil {
+		return nil, newStateError(logBogRepositoryKey, err)
+	}
+	var employeeRepository SKATEmployeeRepository
+	if repositoryJsonAsBytes != nil {
+		err = json.Unmarshal(repositoryJsonAsBytes, &employeeRepository)
+		if err != nil {
+			return nil, newDecodeError(logBogRepositoryKey, err)
+		}
+	}
+
+	migration := repositoryMigration{Skipped: []skippedRecord{}}
+	var remaining []SKATEmployee
+	for _, legacy := range employeeRepository.EmployeeList {
+		skatEmployee := legacy
+		legacyKey := strconv.Itoa(skatEmployee.CPRNum) + "_" + strconv.Itoa(skatEmployee.VirkNum) + "_" + skatEmployee.DateOfWork
+		// update, retract and history only find canonical dates, so a free-text date that cannot be read stays behind
+		dateOfWork, err := normaliseDateOfWork(skatEmployee.DateOfWork)
+		if err != nil {
+			fmt.Println("skipping unparseable DateOfWork " + skatEmployee.DateOfWork)
+			migration.Skipped = append(migration.Skipped, skippedRecord{CPRNum: legacy.CPRNum, VirkNum: legacy.VirkNum, DateOfWork: legacy.DateOfWork, LegacyKey: legacyKey, Code: errCodeInvalidArgument, Message: "DateOfWork " + err.Error()})
+			remaining = append(remaining, legacy)
+			continue
 		}
-	finalEmployeeRepository.EmployeeList = append(finalEmployeeRepository.EmployeeList,employee)
-	//update Employee Repository
-	updatedRepositoryJsonAsBytes, _  := json.Marshal(finalEmployeeRepository)
-	err = stub.PutState("SKATEmployeeRepository", updatedRepositoryJsonAsBytes)	//store employee with id as key
+		skatEmployee.DateOfWork = dateOfWork
+		err = upgradeLogBogEntry(&skatEmployee)
+		if err != nil {
+			return nil, newDecodeError(logBogRepositoryKey, err)
+		}
+		skatEmployee, err = sealLogBogEntry(stub, skatEmployee)
+		if err != nil {
+			return nil, err
+		}
+		key := logBogEntryKey(skatEmployee)
+		existing, err := stub.GetState(key)
+		if err != nil {
+			return nil, newStateError(key, err)
+		}
+		if existing != nil {
+			fmt.Println("skipping " + legacyKey + ", " + key + " already exists")
+			migration.Skipped = append(migration.Skipped, skippedRecord{CPRNum: legacy.CPRNum, VirkNum: legacy.VirkNum, DateOfWork: legacy.DateOfWork, LegacyKey: legacyKey, EntryKey: key, Code: errCodeAlreadyExists, Message: "Employee log already exists for " + legacyKey})
+			remaining = append(remaining, legacy)
+			continue
+		}
+		// an entry deleted before the migration continues its old history chain
+		lastVersion, err := lastLogBogVersion(stub, skatEmployee)
+		if err != nil {
+			return nil, err
+		}
+		skatEmployee.Version = lastVersion + 1
+		err = putLogBogEntry(stub, skatEmployee)
+		if err != nil {
+			return nil, err
+		}
+		err = appendLogBogVersion(stub, opMigrate, skatEmployee)
+		if err != nil {
+			return nil, err
+		}
+		// drop the pre-index CPRNum_VirkNum_DateOfWork key written alongside the blob
+		err = stub.DelState(legacyKey)
+		if err != nil {
+			return nil, newStateError(legacyKey, err)
+		}
+		migration.Migrated++
+	}
+	if len(remaining) > 0 {
+		repositoryJsonAsBytes, err = json.Marshal(SKATEmployeeRepository{EmployeeList: remaining})
+		if err != nil {
+			return nil, err
+		}
+		err = stub.PutState(logBogRepositoryKey, repositoryJsonAsBytes)
+	} else if repositoryJsonAsBytes != nil {
+		err = stub.DelState(logBogRepositoryKey)
+	}
 	if err != nil {
-		return false, err
-	}		
-	return true, nil
+		return nil, newStateError(logBogRepositoryKey, err)
+	}
+	fmt.Println("migrated " + strconv.Itoa(migration.Migrated) + " entries from " + logBogRepositoryKey + ", skipped " + strconv.Itoa(len(migration.Skipped)))
+
+	return json.Marshal(migration)
 }
-	
@@ -0,0 +1,1449 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// testStub adds what shim.MockStub does not provide: certificate attributes,
+// invocation metadata and a transaction timestamp. It also records the event
+// the last transaction set.
+type testStub struct {
+	*shim.MockStub
+	attrs    map[string]string
+	metadata []byte
+	txTime   time.Time
+
+	eventName    string
+	eventPayload []byte
+}
+
+func (s *testStub) ReadCertAttribute(name string) ([]byte, error) {
+	value, ok := s.attrs[name]
+	if !ok {
+		return nil, errors.New("attribute " + name + " not found")
+	}
+	return []byte(value), nil
+}
+
+func (s *testStub) GetCallerCertificate() ([]byte, error) {
+	return nil, nil
+}
+
+func (s *testStub) GetCallerMetadata() ([]byte, error) {
+	return s.metadata, nil
+}
+
+func (s *testStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	if s.txTime.IsZero() {
+		return nil, nil
+	}
+	return &timestamp.Timestamp{Seconds: s.txTime.Unix()}, nil
+}
+
+func (s *testStub) SetEvent(name string, payload []byte) error {
+	s.eventName = name
+	s.eventPayload = payload
+	return nil
+}
+
+// testTxTime is the transaction date of every test transaction.
+var testTxTime = time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+
+const (
+	testCPR   = "0101901234"
+	testCPR2  = "0202851234"
+	testVirk  = "12345678"
+	testVirk2 = "87654321"
+)
+
+// testLedger is one chaincode instance on a MockStub, invoked as different callers.
+type testLedger struct {
+	t    *testing.T
+	cc   *SimpleChaincode
+	mock *shim.MockStub
+	txs  int
+
+	admin    *testStub
+	auditor  *testStub
+	employer *testStub
+	employee *testStub
+}
+
+// newTestLedger initialises a ledger with config, "" for the defaults, and
+// binds the employer to testVirk.
+func newTestLedger(t *testing.T, config string) *testLedger {
+	cc := new(SimpleChaincode)
+	l := &testLedger{t: t, cc: cc, mock: shim.NewMockStub("logbog", cc)}
+	l.admin = l.caller(map[string]string{roleAttribute: roleAdmin, identityAttribute: "skat-admin"})
+	l.auditor = l.caller(map[string]string{roleAttribute: roleAuditor, identityAttribute: "skat-auditor"})
+	l.employer = l.caller(map[string]string{roleAttribute: roleEmployer, identityAttribute: "acme"})
+	l.employee = l.caller(map[string]string{roleAttribute: roleEmployee, identityAttribute: "bob", cprNumAttribute: testCPR})
+
+	args := []string{"hello"}
+	if len(config) > 0 {
+		args = append(args, config)
+	}
+	l.mustInvoke(l.admin, "init", args...)
+	l.mustInvoke(l.admin, "bindVirkNum", "acme", testVirk)
+	return l
+}
+
+func (l *testLedger) caller(attrs map[string]string) *testStub {
+	return &testStub{MockStub: l.mock, attrs: attrs, txTime: testTxTime}
+}
+
+// invoke runs function as caller in a transaction of its own.
+func (l *testLedger) invoke(caller *testStub, function string, args ...string) ([]byte, error) {
+	l.txs++
+	txID := "tx" + strconv.Itoa(l.txs)
+	caller.eventName, caller.eventPayload = "", nil
+	l.mock.MockTransactionStart(txID)
+	defer l.mock.MockTransactionEnd(txID)
+	return l.cc.Invoke(caller, function, args)
+}
+
+// query runs function as caller outside any transaction, so a query that
+// tries to write state fails.
+func (l *testLedger) query(caller *testStub, function string, args ...string) ([]byte, error) {
+	return l.cc.Query(caller, function, args)
+}
+
+func (l *testLedger) mustInvoke(caller *testStub, function string, args ...string) []byte {
+	out, err := l.invoke(caller, function, args...)
+	if err != nil {
+		l.t.Fatalf("%s%v: %v", function, args, err)
+	}
+	return out
+}
+
+func (l *testLedger) mustQuery(caller *testStub, function string, args ...string) []byte {
+	out, err := l.query(caller, function, args...)
+	if err != nil {
+		l.t.Fatalf("%s%v: %v", function, args, err)
+	}
+	return out
+}
+
+func (l *testLedger) add(cpr, virk, date, hours string) {
+	l.mustInvoke(l.admin, "addToLogBog", cpr, virk, "Navn", date, hours)
+}
+
+func (l *testLedger) search(args ...string) []SKATEmployee {
+	var employees []SKATEmployee
+	decode(l.t, l.mustQuery(l.admin, "searchLogBog", args...), &employees)
+	return employees
+}
+
+// snapshot copies the state so a failed call can be checked to have written nothing.
+func (l *testLedger) snapshot() map[string]string {
+	state := map[string]string{}
+	for key, value := range l.mock.State {
+		state[key] = string(value)
+	}
+	return state
+}
+
+func (l *testLedger) expectUnchanged(before map[string]string, what string) {
+	after := l.snapshot()
+	if len(after) != len(before) {
+		l.t.Errorf("%s: state has %d keys, had %d", what, len(after), len(before))
+	}
+	for key, value := range before {
+		if after[key] != value {
+			l.t.Errorf("%s: changed %s", what, key)
+		}
+	}
+}
+
+// checkIndexes asserts every entry has both index keys pointing at it and
+// every index key points at an existing entry.
+func (l *testLedger) checkIndexes() {
+	entries := 0
+	for key, value := range l.mock.State {
+		switch {
+		case strings.HasPrefix(key, logBogEntryPrefix):
+			entries++
+			employee, err := decodeLogBogEntry(key, value)
+			if err != nil {
+				l.t.Fatal(err)
+			}
+			if logBogEntryKey(employee) != key {
+				l.t.Errorf("entry %s is keyed as %s", key, logBogEntryKey(employee))
+			}
+			for _, index := range []string{logBogVirkIndexKey(employee), logBogDateIndexKey(employee)} {
+				if string(l.mock.State[index]) != key {
+					l.t.Errorf("index %s = %q, want %s", index, l.mock.State[index], key)
+				}
+			}
+		case strings.HasPrefix(key, logBogVirkIndexPrefix), strings.HasPrefix(key, logBogDateIndexPrefix):
+			if _, ok := l.mock.State[string(value)]; !ok {
+				l.t.Errorf("index %s points at missing entry %s", key, value)
+			}
+		}
+	}
+	indexes := 0
+	for key := range l.mock.State {
+		if strings.HasPrefix(key, logBogVirkIndexPrefix) || strings.HasPrefix(key, logBogDateIndexPrefix) {
+			indexes++
+		}
+	}
+	if indexes != 2*entries {
+		l.t.Errorf("%d index keys for %d entries", indexes, entries)
+	}
+}
+
+func decode(t *testing.T, jsonAsBytes []byte, v interface{}) {
+	err := json.Unmarshal(jsonAsBytes, v)
+	if err != nil {
+		t.Fatalf("decode %s: %v", jsonAsBytes, err)
+	}
+}
+
+// expectCode asserts err is a logBogError with code and, unless empty, field.
+func expectCode(t *testing.T, what string, err error, code, field string) {
+	logBogErr, ok := err.(*logBogError)
+	if !ok {
+		t.Errorf("%s: got %v, want %s error", what, err, code)
+		return
+	}
+	if logBogErr.Code != code || (len(field) > 0 && logBogErr.Field != field) {
+		t.Errorf("%s: got %s on %q (%s), want %s on %q", what, logBogErr.Code, logBogErr.Field, logBogErr.Message, code, field)
+	}
+}
+
+func TestInit(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		code  string
+		field string
+	}{
+		{"no args", []string{}, errCodeInvalidArgumentCount, ""},
+		{"too many args", []string{"a", "{}", "c"}, errCodeInvalidArgumentCount, ""},
+		{"config not JSON", []string{"a", "grace"}, errCodeInvalidArgument, "config"},
+		{"negative grace", []string{"a", `{"dateGraceDays":-1}`}, errCodeInvalidArgument, "config"},
+		{"unknown rule mode", []string{"a", `{"workingTimeRules":"warn"}`}, errCodeInvalidArgument, "config"},
+		{"greeting only", []string{"a"}, "", ""},
+		{"empty greeting", []string{""}, "", ""},
+		{"with config", []string{"a", `{"dateGraceDays":3}`}, "", ""},
+	}
+	for _, test := range tests {
+		cc := new(SimpleChaincode)
+		stub := shim.NewMockStub("logbog", cc)
+		_, err := stub.MockInit("init", "init", test.args)
+		if len(test.code) > 0 {
+			expectCode(t, test.name, err, test.code, test.field)
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if string(stub.State["hello_Block"]) != test.args[0] {
+			t.Errorf("%s: hello_Block = %q", test.name, stub.State["hello_Block"])
+		}
+		if _, ok := stub.State[logBogConfigKey]; !ok {
+			t.Errorf("%s: config not stored", test.name)
+		}
+	}
+}
+
+func TestInitKeepsPseudonymiseOnceEntriesExist(t *testing.T) {
+	l := newTestLedger(t, "")
+	l.add(testCPR, testVirk, "2026-01-05", "7")
+	_, err := l.invoke(l.admin, "init", "hello", `{"pseudonymise":true}`)
+	expectCode(t, "switch on", err, errCodeInvalidArgument, "config")
+	l.mustInvoke(l.admin, "init", "hello", `{"dateGraceDays":2}`)
+}
+
+func TestUnknownFunction(t *testing.T) {
+	l := newTestLedger(t, "")
+	_, err := l.invoke(l.admin, "dropLogBog")
+	expectCode(t, "invoke", err, errCodeUnknownFunction, "")
+	_, err = l.query(l.admin, "dropLogBog")
+	expectCode(t, "query", err, errCodeUnknownFunction, "")
+	// queries are not dispatched by Invoke, nor invokes by Query
+	_, err = l.invoke(l.admin, "searchLogBog", "", testVirk)
+	expectCode(t, "search as invoke", err, errCodeUnknownFunction, "")
+	_, err = l.query(l.admin, "addToLogBog", testCPR, testVirk, "n", "2026-01-05", "7")
+	expectCode(t, "add as query", err, errCodeUnknownFunction, "")
+}
+
+// TestEveryFunctionIsDispatched calls each function in logBogArgSpecs with
+// too few arguments, which fails validation before dispatch, and with its
+// arguments left empty, which must reach a handler in Invoke or Query.
+func TestEveryFunctionIsDispatched(t *testing.T) {
+	for function, specs := range logBogArgSpecs {
+		if _, ok := logBogAccessPolicy[function]; !ok {
+			t.Errorf("%s has no access rule", function)
+		}
+		if function == "init" {
+			continue
+		}
+		l := newTestLedger(t, "")
+		args := make([]string, len(specs))
+		for i := range args {
+			args[i] = "x"
+		}
+		_, invokeErr := l.invoke(l.admin, function, args...)
+		_, queryErr := l.query(l.admin, function, args...)
+		if isLogBogError(invokeErr, errCodeUnknownFunction) && isLogBogError(queryErr, errCodeUnknownFunction) {
+			t.Errorf("%s is validated but dispatched by neither Invoke nor Query", function)
+		}
+		required := 0
+		for _, spec := range specs {
+			if !spec.Optional {
+				required++
+			}
+		}
+		if required > 0 {
+			_, err := l.invoke(l.admin, function, args[:required-1]...)
+			if !isLogBogError(err, errCodeInvalidArgumentCount) {
+				_, err = l.query(l.admin, function, args[:required-1]...)
+			}
+			expectCode(t, function+" short", err, errCodeInvalidArgumentCount, "")
+		}
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	l := newTestLedger(t, "")
+	var value versionedValue
+	decode(t, l.mustInvoke(l.admin, "write", "greeting", "hej"), &value)
+	if value != (versionedValue{Key: "greeting", Value: "hej", Version: 1}) {
+		t.Errorf("write = %+v", value)
+	}
+	if read := string(l.mustQuery(l.auditor, "read", "greeting")); read != "hej" {
+		t.Errorf("read = %q", read)
+	}
+	decode(t, l.mustQuery(l.auditor, "readVersion", "greeting"), &value)
+	if value != (versionedValue{Key: "greeting", Value: "hej", Version: 1}) {
+		t.Errorf("readVersion = %+v", value)
+	}
+	if read := l.mustQuery(l.admin, "read", "missing"); read != nil {
+		t.Errorf("read missing = %q", read)
+	}
+	decode(t, l.mustQuery(l.admin, "readVersion", "missing"), &value)
+	if value != (versionedValue{Key: "missing"}) {
+		t.Errorf("readVersion missing = %+v", value)
+	}
+	_, err := l.invoke(l.employer, "write", "greeting", "x")
+	expectCode(t, "employer write", err, errCodePermissionDenied, "")
+	_, err = l.query(l.employee, "read", "greeting")
+	expectCode(t, "employee read", err, errCodePermissionDenied, "")
+	_, err = l.query(l.employee, "readVersion", "greeting")
+	expectCode(t, "employee readVersion", err, errCodePermissionDenied, "")
+	_, err = l.invoke(l.admin, "write", "", "x")
+	expectCode(t, "empty key", err, errCodeInvalidArgument, "key")
+
+	// keys the chaincode manages itself cannot be written, whether stored yet or not
+	l.add(testCPR, testVirk, "2026-01-05", "7")
+	l.mustInvoke(l.admin, "retractLogBog", testCPR, testVirk, "2026-01-05", "wrong")
+	managed := []string{logBogRepositoryKey, "LogBog", logBogErasurePrefix + "x"}
+	for key := range l.mock.State {
+		if strings.HasPrefix(key, "LogBog") {
+			managed = append(managed, key)
+		}
+	}
+	for _, key := range managed {
+		before := l.snapshot()
+		_, err = l.invoke(l.admin, "write", key, "9")
+		expectCode(t, "write "+key, err, errCodeInvalidArgument, "key")
+		l.expectUnchanged(before, "write "+key)
+	}
+	l.mustInvoke(l.admin, "write", "SKATEmployeeRepository2", "fine")
+	l.mustInvoke(l.admin, "write", "logbog", "fine")
+}
+
+func TestWriteCompareAndSet(t *testing.T) {
+	l := newTestLedger(t, "")
+	var value versionedValue
+
+	// two clients read version 1; the first write wins, the second conflicts
+	l.mustInvoke(l.admin, "write", "greeting", "hej")
+	decode(t, l.mustInvoke(l.admin, "write", "greeting", "hello", "1"), &value)
+	if value.Version != 2 || value.Value != "hello" {
+		t.Errorf("conditional write = %+v", value)
+	}
+	before := l.snapshot()
+	_, err := l.invoke(l.admin, "write", "greeting", "hola", "1")
+	expectCode(t, "stale write", err, errCodeVersionConflict, "expectedVersion")
+	if logBogErr, ok := err.(*logBogError); ok && logBogErr.Key != "greeting" {
+		t.Errorf("stale write Key = %q", logBogErr.Key)
+	}
+	l.expectUnchanged(before, "stale write")
+
+	// an unconditional write still goes through and bumps the version
+	decode(t, l.mustInvoke(l.admin, "write", "greeting", "hola", ""), &value)
+	if value.Version != 3 {
+		t.Errorf("unconditional write = %+v", value)
+	}
+
+	// 0 creates a key only if it holds nothing yet
+	decode(t, l.mustInvoke(l.admin, "write", "fresh", "a", "0"), &value)
+	if value.Version != 1 {
+		t.Errorf("create = %+v", value)
+	}
+	_, err = l.invoke(l.admin, "write", "fresh", "b", "0")
+	expectCode(t, "create existing", err, errCodeVersionConflict, "expectedVersion")
+
+	// a value stored without a version counts as version 1
+	l.mock.MockTransactionStart("legacy")
+	l.mock.PutState("legacy", []byte("old"))
+	l.mock.MockTransactionEnd("legacy")
+	decode(t, l.mustQuery(l.admin, "readVersion", "legacy"), &value)
+	if value.Version != 1 || value.Value != "old" {
+		t.Errorf("read legacy = %+v", value)
+	}
+	_, err = l.invoke(l.admin, "write", "legacy", "new", "0")
+	expectCode(t, "create legacy", err, errCodeVersionConflict, "expectedVersion")
+	decode(t, l.mustInvoke(l.admin, "write", "legacy", "new", "1"), &value)
+	if value.Version != 2 {
+		t.Errorf("write legacy = %+v", value)
+	}
+
+	for _, expected := range []string{"-1", "one", "1.5"} {
+		_, err = l.invoke(l.admin, "write", "greeting", "x", expected)
+		expectCode(t, "expectedVersion "+expected, err, errCodeInvalidArgument, "expectedVersion")
+	}
+}
+
+func TestArgumentValidation(t *testing.T) {
+	tests := []struct {
+		function string
+		args     []string
+		code     string
+		field    string
+	}{
+		{"addToLogBog", []string{testCPR, testVirk, "n", "2026-01-05"}, errCodeInvalidArgumentCount, ""},
+		{"addToLogBog", []string{testCPR, testVirk, "n", "2026-01-05", "7", "c", "08:00", "16:00", "x"}, errCodeInvalidArgumentCount, ""},
+		{"addToLogBog", []string{"12345", testVirk, "n", "2026-01-05", "7"}, errCodeInvalidArgument, "CPRNum"},
+		{"addToLogBog", []string{"3201901234", testVirk, "n", "2026-01-05", "7"}, errCodeInvalidArgument, "CPRNum"},
+		{"addToLogBog", []string{testCPR, "01234567", "n", "2026-01-05", "7"}, errCodeInvalidArgument, "VirkNum"},
+		{"addToLogBog", []string{testCPR, testVirk, "", "2026-01-05", "7"}, errCodeInvalidArgument, "CPRNavn"},
+		{"addToLogBog", []string{testCPR, testVirk, "n", "yesterday", "7"}, errCodeInvalidArgument, "DateOfWork"},
+		{"addToLogBog", []string{testCPR, testVirk, "n", "2026-03-01", "7"}, errCodeInvalidArgument, "DateOfWork"},
+		{"addToLogBog", []string{testCPR, testVirk, "n", "2026-01-05", "seven"}, errCodeInvalidArgument, "NoOfHours"},
+		{"addToLogBog", []string{testCPR, testVirk, "n", "2026-01-05", "25"}, errCodeInvalidArgument, "NoOfHours"},
+		{"addToLogBog", []string{testCPR, testVirk, "n", "2026-01-05", ""}, errCodeInvalidArgument, "NoOfHours"},
+		{"addToLogBog", []string{testCPR, testVirk, "n", "2026-01-05", "-0:30"}, errCodeInvalidArgument, "NoOfHours"},
+		{"addToLogBog", []string{testCPR, testVirk, "n", "2026-01-05", "+7:30"}, errCodeInvalidArgument, "NoOfHours"},
+		{"addToLogBog", []string{testCPR, testVirk, "n", "2026-01-05", "7:-5"}, errCodeInvalidArgument, "NoOfHours"},
+		{"addToLogBog", []string{testCPR, testVirk, "n", "2026-01-05", ":30"}, errCodeInvalidArgument, "NoOfHours"},
+		{"addToLogBog", []string{testCPR, testVirk, "n", "2026-01-05", "", "", "08:00"}, errCodeInvalidArgument, "EndTime"},
+		{"addToLogBog", []string{testCPR, testVirk, "n", "2026-01-05", "9", "", "08:00", "16:00"}, errCodeInvalidArgument, "NoOfHours"},
+		{"upsertLogBog", []string{testCPR, testVirk, "n", "2026-01-05", "-1"}, errCodeInvalidArgument, "NoOfHours"},
+		{"updateLogBog", []string{testCPR, testVirk, "2026-01-05"}, errCodeInvalidArgumentCount, ""},
+		{"updateLogBog", []string{testCPR, testVirk, "2026-01-05", `{}`}, errCodeInvalidArgument, "patch"},
+		{"updateLogBog", []string{testCPR, testVirk, "2026-01-05", `{"VirkNum":1}`}, errCodeInvalidArgument, "VirkNum"},
+		{"updateLogBog", []string{testCPR, testVirk, "2026-01-05", `{"NoOfHours":30}`}, errCodeInvalidArgument, "NoOfHours"},
+		{"updateLogBog", []string{testCPR, testVirk, "2026-01-05", `{"CPRNavn":""}`}, errCodeInvalidArgument, "CPRNavn"},
+		{"retractLogBog", []string{testCPR, testVirk, "2026-01-05", ""}, errCodeInvalidArgument, "reason"},
+		{"retractLogBog", []string{testCPR, testVirk, "2026-01-05", "r", "purge"}, errCodeInvalidArgument, "mode"},
+		{"historyLogBog", []string{testCPR, testVirk, "someday"}, errCodeInvalidArgument, "DateOfWork"},
+		{"searchLogBog", []string{"", ""}, "", ""},
+		{"searchLogBog", []string{"", "", "2026-01-31", "2026-01-01"}, errCodeInvalidArgument, "to"},
+		{"searchLogBog", []string{"", testVirk, "", "", "maybe"}, errCodeInvalidArgument, "includeRetracted"},
+		{"searchLogBog", []string{"", testVirk, "", "", "", "0"}, errCodeInvalidArgument, "pageSize"},
+		{"searchLogBog", []string{"", testVirk, "", "", "", "", "abc"}, errCodeInvalidArgument, "pageToken"},
+		{"searchLogBog", []string{"", testVirk, "", "", "", "10", "!!"}, errCodeInvalidArgument, "pageToken"},
+		{"reportHours", []string{"year", "2026-01-01", "2026-01-31"}, errCodeInvalidArgument, "groupBy"},
+		{"reportHours", []string{"day,month", "2026-01-01", "2026-01-31"}, errCodeInvalidArgument, "groupBy"},
+		{"reportHours", []string{"cpr", "", "2026-01-31"}, errCodeInvalidArgument, "from"},
+		{"migrateLogBog", []string{"1000"}, errCodeInvalidArgument, "batchSize"},
+		{"bindVirkNum", []string{"acme", "123"}, errCodeInvalidArgument, "VirkNum"},
+		{"eraseSubject", []string{testCPR}, errCodeInvalidArgumentCount, ""},
+		{"getVirkBinding", []string{}, errCodeInvalidArgumentCount, ""},
+	}
+	l := newTestLedger(t, "")
+	for _, test := range tests {
+		before := l.snapshot()
+		_, err := l.invoke(l.admin, test.function, test.args...)
+		if isLogBogError(err, errCodeUnknownFunction) {
+			_, err = l.query(l.admin, test.function, test.args...)
+		}
+		what := test.function + strings.Join(test.args, ",")
+		if len(test.code) == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", what, err)
+			}
+			continue
+		}
+		expectCode(t, what, err, test.code, test.field)
+		l.expectUnchanged(before, what)
+	}
+}
+
+func TestDateOfWork(t *testing.T) {
+	l := newTestLedger(t, "")
+	for i, date := range []string{"2026-01-05", "6-1-2026", "07.01.2026", "8 Jan 2026", "20260109"} {
+		l.add(testCPR, testVirk, date, "7")
+		if found := l.search(testCPR, testVirk); len(found) != i+1 || found[i].DateOfWork != "2026-01-0"+strconv.Itoa(5+i) {
+			t.Errorf("%s stored as %+v", date, found)
+		}
+	}
+
+	tests := []struct {
+		config  string
+		date    string
+		allowed bool
+	}{
+		{"", "2026-02-02", true},
+		{"", "2026-02-03", false},
+		{`{"dateGraceDays":0}`, "2026-02-01", true},
+		{`{"dateGraceDays":0}`, "2026-02-02", false},
+		{`{"dateGraceDays":30}`, "2026-03-03", true},
+	}
+	for _, test := range tests {
+		l := newTestLedger(t, test.config)
+		_, err := l.invoke(l.admin, "addToLogBog", testCPR, testVirk, "n", test.date, "7")
+		if test.allowed && err != nil {
+			t.Errorf("%s with %q: %v", test.date, test.config, err)
+		}
+		if !test.allowed {
+			expectCode(t, test.date+" with "+test.config, err, errCodeInvalidArgument, "DateOfWork")
+		}
+	}
+}
+
+func TestDateOfWorkNeedsTxTimestamp(t *testing.T) {
+	l := newTestLedger(t, "")
+	untimed := l.caller(l.employer.attrs)
+	untimed.txTime = time.Time{}
+	before := l.snapshot()
+	_, err := l.invoke(untimed, "addToLogBog", testCPR, testVirk, "n", "2026-01-05", "7")
+	expectCode(t, "add without timestamp", err, errCodeStateFailure, "DateOfWork")
+	_, err = l.invoke(untimed, "bulkAddToLogBog", "CPRNum,VirkNum,CPRNavn,DOW,NoOfHours\n"+testCPR+","+testVirk+",n,2026-01-05,7")
+	expectCode(t, "bulk add without timestamp", err, errCodeBatchRejected, "")
+	l.expectUnchanged(before, "without timestamp")
+}
+
+func TestAddAndUpsert(t *testing.T) {
+	l := newTestLedger(t, "")
+	out := l.mustInvoke(l.employer, "addToLogBog", testCPR, testVirk, "Bob Jensen", "05-01-2026", "7,5", "first")
+	var added SKATEmployee
+	decode(t, out, &added)
+	if added.DateOfWork != "2026-01-05" || added.CPRNavn != "bob jensen" || added.NoOfHours != 7.5 || added.Version != 1 {
+		t.Errorf("added %+v", added)
+	}
+
+	before := l.snapshot()
+	_, err := l.invoke(l.employer, "addToLogBog", testCPR, testVirk, "Bob", "2026-01-05", "8")
+	expectCode(t, "duplicate add", err, errCodeAlreadyExists, "")
+	l.expectUnchanged(before, "duplicate add")
+
+	out = l.mustInvoke(l.employer, "upsertLogBog", testCPR, testVirk, "Bob", "2026-01-05", "8")
+	var upserted SKATEmployee
+	decode(t, out, &upserted)
+	if upserted.NoOfHours != 8 || upserted.Comment != "" || upserted.Version != 2 {
+		t.Errorf("upserted %+v", upserted)
+	}
+	l.mustInvoke(l.employer, "upsertLogBog", testCPR, testVirk, "Bob", "2026-01-06", "", "", "08:00", "15:30")
+	if found := l.search(testCPR, testVirk, "2026-01-06", "2026-01-06"); len(found) != 1 || found[0].NoOfHours != 7.5 {
+		t.Errorf("derived hours %+v", found)
+	}
+	l.checkIndexes()
+}
+
+// TestMissingEntryIsNotFound covers every function that looks an entry up
+// by CPRNum, VirkNum and DateOfWork: none may act on a zero record.
+func TestMissingEntryIsNotFound(t *testing.T) {
+	l := newTestLedger(t, "")
+	l.add(testCPR, testVirk, "2026-01-05", "7")
+	tests := []struct {
+		function string
+		args     []string
+	}{
+		{"updateLogBog", []string{testCPR, testVirk, "2026-01-06", `{"NoOfHours":1}`}},
+		{"updateLogBog", []string{testCPR2, testVirk, "2026-01-05", "comment"}},
+		{"retractLogBog", []string{testCPR, testVirk2, "2026-01-05", "r"}},
+		{"retractLogBog", []string{testCPR, testVirk, "2026-01-04", "r", "hard"}},
+		{"historyLogBog", []string{testCPR, testVirk, "2026-01-06"}},
+		{"eraseSubject", []string{testCPR2, "case-1"}},
+	}
+	for _, test := range tests {
+		before := l.snapshot()
+		_, err := l.invoke(l.admin, test.function, test.args...)
+		if isLogBogError(err, errCodeUnknownFunction) {
+			_, err = l.query(l.admin, test.function, test.args...)
+		}
+		expectCode(t, test.function, err, errCodeNotFound, "")
+		l.expectUnchanged(before, test.function)
+	}
+}
+
+func TestUpdateMissingEntryWritesNothing(t *testing.T) {
+	for _, config := range []string{"", `{"pseudonymise":true}`} {
+		l := newTestLedger(t, config)
+		l.admin.metadata = []byte(testKeyMetadata)
+		for _, patch := range []string{`{"NoOfHours":1}`, `{"CPRNavn":"Bob","Comment":"c"}`, "comment"} {
+			before := l.snapshot()
+			_, err := l.invoke(l.admin, "updateLogBog", testCPR, testVirk, "2026-01-05", patch)
+			expectCode(t, config+" update "+patch, err, errCodeNotFound, "")
+			if logBogErr, ok := err.(*logBogError); ok && !strings.HasPrefix(logBogErr.Key, logBogEntryPrefix) {
+				t.Errorf("%s update %s: Key = %q", config, patch, logBogErr.Key)
+			}
+			l.expectUnchanged(before, config+" update "+patch)
+		}
+		for key := range l.mock.State {
+			if strings.HasPrefix(key, "0_0_") || strings.Contains(key, "_0_0_") || key == logBogRepositoryKey {
+				t.Errorf("%s: zero record %s written", config, key)
+			}
+		}
+
+		_, err := l.cc.getEmployeeLog(l.admin, testCPR, testVirk, "5-1-2026")
+		expectCode(t, config+" getEmployeeLog", err, errCodeNotFound, "")
+	}
+}
+
+func TestUpdateLogBog(t *testing.T) {
+	tests := []struct {
+		patch   string
+		hours   float64
+		navn    string
+		comment string
+		changed []string
+	}{
+		{"sick", 7, "navn", "sick", []string{"Comment"}},
+		{`{"NoOfHours":6}`, 6, "navn", "", []string{"NoOfHours"}},
+		{`{"NoOfHours":"6:45","Comments":"late"}`, 6.75, "navn", "late", []string{"NoOfHours", "Comment"}},
+		{`{"CPRNavn":"Bob"}`, 7, "bob", "", []string{"CPRNavn"}},
+	}
+	for _, test := range tests {
+		l := newTestLedger(t, "")
+		l.add(testCPR, testVirk, "2026-01-05", "7")
+		out := l.mustInvoke(l.employer, "updateLogBog", testCPR, testVirk, "2026-01-05", test.patch)
+		var result map[string]SKATEmployee
+		decode(t, out, &result)
+		after := result["after"]
+		if after.NoOfHours != test.hours || after.CPRNavn != test.navn || after.Comment != test.comment || after.Version != 2 {
+			t.Errorf("%s: after %+v", test.patch, after)
+		}
+		if result["before"].NoOfHours != 7 || result["before"].Version != 1 {
+			t.Errorf("%s: before %+v", test.patch, result["before"])
+		}
+		var event logBogEvent
+		decode(t, l.employer.eventPayload, &event)
+		if len(event.Changes) != 1 || strings.Join(event.Changes[0].ChangedFields, ",") != strings.Join(test.changed, ",") {
+			t.Errorf("%s: event %s", test.patch, l.employer.eventPayload)
+		}
+	}
+}
+
+func TestRetractLogBog(t *testing.T) {
+	l := newTestLedger(t, "")
+	l.add(testCPR, testVirk, "2026-01-05", "7")
+	l.add(testCPR, testVirk, "2026-01-06", "7")
+
+	l.mustInvoke(l.employer, "retractLogBog", testCPR, testVirk, "2026-01-05", "wrong day")
+	if found := l.search(testCPR, ""); len(found) != 1 || found[0].DateOfWork != "2026-01-06" {
+		t.Errorf("search hides retracted: %+v", found)
+	}
+	found := l.search(testCPR, "", "", "", "true")
+	if len(found) != 2 || !found[0].Retracted || found[0].RetractReason != "wrong day" || found[0].RetractedAt != testTxTime.Format(time.RFC3339) {
+		t.Errorf("includeRetracted: %+v", found)
+	}
+	_, err := l.invoke(l.employer, "retractLogBog", testCPR, testVirk, "2026-01-05", "again")
+	expectCode(t, "retract twice", err, errCodeRetracted, "")
+	_, err = l.invoke(l.employer, "updateLogBog", testCPR, testVirk, "2026-01-05", "x")
+	expectCode(t, "update retracted", err, errCodeRetracted, "")
+
+	_, err = l.invoke(l.employer, "retractLogBog", testCPR, testVirk, "2026-01-06", "gone", "hard")
+	expectCode(t, "employer hard delete", err, errCodePermissionDenied, "")
+	l.mustInvoke(l.admin, "retractLogBog", testCPR, testVirk, "2026-01-06", "gone", "hard")
+	if found := l.search(testCPR, "", "", "", "true"); len(found) != 1 {
+		t.Errorf("after hard delete: %+v", found)
+	}
+	l.checkIndexes()
+
+	// a hard-deleted entry added again continues its history
+	l.add(testCPR, testVirk, "2026-01-06", "3")
+	var versions []logBogVersion
+	decode(t, l.mustQuery(l.admin, "historyLogBog", testCPR, testVirk, "2026-01-06"), &versions)
+	operations := []string{}
+	for _, version := range versions {
+		operations = append(operations, version.Operation)
+	}
+	if strings.Join(operations, ",") != "add,delete,add" || versions[2].Version != 3 {
+		t.Errorf("history %s", operations)
+	}
+}
+
+func TestHistoryLogBog(t *testing.T) {
+	l := newTestLedger(t, "")
+	l.add(testCPR, testVirk, "2026-01-05", "7")
+	l.mustInvoke(l.employer, "upsertLogBog", testCPR, testVirk, "Navn", "2026-01-05", "8")
+	l.mustInvoke(l.employer, "updateLogBog", testCPR, testVirk, "2026-01-05", `{"NoOfHours":9}`)
+	l.mustInvoke(l.employer, "retractLogBog", testCPR, testVirk, "2026-01-05", "r")
+
+	var versions []logBogVersion
+	decode(t, l.mustQuery(l.employee, "historyLogBog", testCPR, testVirk, "2026-01-05"), &versions)
+	want := []struct {
+		operation string
+		hours     float64
+		submitter string
+	}{
+		{opAdd, 7, "skat-admin"},
+		{opUpsert, 8, "acme"},
+		{opUpdate, 9, "acme"},
+		{opRetract, 9, "acme"},
+	}
+	if len(versions) != len(want) {
+		t.Fatalf("got %d versions", len(versions))
+	}
+	for i, version := range versions {
+		if version.Version != i+1 || version.Operation != want[i].operation || version.Record.NoOfHours != want[i].hours ||
+			version.Submitter != want[i].submitter || len(version.TxID) == 0 || version.Timestamp != testTxTime.Format(time.RFC3339) {
+			t.Errorf("version %d: %+v", i+1, version)
+		}
+	}
+}
+
+func TestSearchCombinations(t *testing.T) {
+	l := newTestLedger(t, "")
+	l.add(testCPR, testVirk, "2026-01-05", "1")
+	l.add(testCPR, testVirk, "2026-01-20", "2")
+	l.add(testCPR, testVirk2, "2026-01-05", "3")
+	l.add(testCPR2, testVirk, "2026-01-05", "4")
+	l.add(testCPR2, testVirk2, "2026-01-31", "5")
+
+	tests := []struct {
+		name  string
+		args  []string
+		hours string
+	}{
+		{"cpr", []string{testCPR, ""}, "1,2,3"},
+		{"cpr with dash", []string{"010190-1234", ""}, "1,2,3"},
+		{"virk", []string{"", testVirk}, "1,2,4"},
+		{"cpr and virk", []string{testCPR, testVirk}, "1,2"},
+		{"cpr and virk from", []string{testCPR, testVirk, "2026-01-10"}, "2"},
+		{"virk to", []string{"", testVirk, "", "2026-01-10"}, "1,4"},
+		{"date only", []string{"", "", "2026-01-05", "2026-01-05"}, "1,3,4"},
+		{"date window in another layout", []string{"", "", "20-01-2026", "31/01/2026"}, "2,5"},
+		{"from only", []string{"", "", "2026-01-06"}, "2,5"},
+		{"nothing", []string{"", ""}, ""},
+		{"no match", []string{testCPR2, testVirk, "2026-01-06"}, ""},
+	}
+	for _, test := range tests {
+		hours := []string{}
+		for _, employee := range l.search(test.args...) {
+			hours = append(hours, formatHours(employee.NoOfHours))
+		}
+		if strings.Join(hours, ",") != test.hours {
+			t.Errorf("%s: got %s, want %s", test.name, strings.Join(hours, ","), test.hours)
+		}
+	}
+}
+
+func TestSearchPaging(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		filters []string
+	}{
+		{"virk", "", []string{"", testVirk, "", ""}},
+		{"cpr", "", []string{testCPR, "", "", ""}},
+		{"date window", "", []string{"", "", "2026-01-01", "2026-01-31"}},
+		{"pseudonymised virk", `{"pseudonymise":true}`, []string{"", testVirk, "", ""}},
+		{"pseudonymised cpr", `{"pseudonymise":true}`, []string{testCPR, "", "2026-01-01", ""}},
+	}
+	for _, test := range tests {
+		l := newTestLedger(t, test.config)
+		l.admin.metadata = []byte(testKeyMetadata)
+		for day := 1; day <= 7; day++ {
+			l.add(testCPR, testVirk, "2026-01-0"+strconv.Itoa(day), strconv.Itoa(day))
+		}
+		token := ""
+		hours := []string{}
+		for pages := 1; ; pages++ {
+			var page logBogPage
+			decode(t, l.mustQuery(l.admin, "searchLogBog", append(test.filters, "", "3", token)...), &page)
+			if page.Total != 7 {
+				t.Errorf("%s: total %d on page %d", test.name, page.Total, pages)
+			}
+			for _, employee := range page.Records {
+				hours = append(hours, formatHours(employee.NoOfHours))
+				if employee.CPRNum != 101901234 || employee.CPRNavn != "navn" || len(employee.CPRNumEnc) > 0 {
+					t.Errorf("%s: record not opened %+v", test.name, employee)
+				}
+			}
+			if token = page.NextToken; len(token) == 0 {
+				break
+			}
+			if pages > 3 {
+				t.Fatalf("%s: paging does not finish", test.name)
+			}
+		}
+		if strings.Join(hours, ",") != "1,2,3,4,5,6,7" {
+			t.Errorf("%s: pages gave %s", test.name, strings.Join(hours, ","))
+		}
+	}
+}
+
+func TestSearchPagingStopsAfterPage(t *testing.T) {
+	l := newTestLedger(t, "")
+	for day := 1; day <= 9; day++ {
+		l.add(testCPR, testVirk, "2026-01-0"+strconv.Itoa(day), strconv.Itoa(day))
+	}
+	matched := 0
+	match := func(SKATEmployee) bool {
+		matched++
+		return true
+	}
+	scan := indexScan(logBogVirkIndexPrefix + testVirk + "_")
+	first, err := pageLogBog(l.mock, scan, match, 2, "")
+	if err != nil || matched != 9 || first.Total != 9 {
+		t.Fatalf("first page read %d entries: %+v %v", matched, first, err)
+	}
+	matched = 0
+	second, err := pageLogBog(l.mock, scan, match, 2, first.NextToken)
+	if err != nil || matched != 3 || second.Total != 9 || len(second.Records) != 2 || second.Records[0].DateOfWork != "2026-01-03" {
+		t.Errorf("later page read %d entries: %+v %v", matched, second, err)
+	}
+}
+
+func TestMigrateLogBogRepository(t *testing.T) {
+	l := newTestLedger(t, "")
+	legacy := []SKATEmployee{
+		{CPRNum: 101901234, VirkNum: 12345678, CPRNavn: "bob", DateOfWork: "5-1-2026", NoOfHours: 7},
+		{CPRNum: 202851234, VirkNum: 12345678, CPRNavn: "al", DateOfWork: "2026-01-06", NoOfHours: 5},
+	}
+	repository, _ := json.Marshal(SKATEmployeeRepository{EmployeeList: legacy})
+	l.mock.MockTransactionStart("seed")
+	l.mock.PutState(logBogRepositoryKey, repository)
+	for _, employee := range legacy {
+		record, _ := json.Marshal(employee)
+		l.mock.PutState(strconv.Itoa(employee.CPRNum)+"_"+strconv.Itoa(employee.VirkNum)+"_"+employee.DateOfWork, record)
+	}
+	l.mock.MockTransactionEnd("seed")
+
+	var result repositoryMigration
+	decode(t, l.mustInvoke(l.admin, "migrateLogBogRepository"), &result)
+	if result.Migrated != 2 || len(result.Skipped) != 0 {
+		t.Errorf("migrated %+v", result)
+	}
+	for key := range l.mock.State {
+		if key == logBogRepositoryKey || strings.HasPrefix(key, "101901234_") || strings.HasPrefix(key, "202851234_") {
+			t.Errorf("legacy key %s left", key)
+		}
+	}
+	l.checkIndexes()
+	found := l.search("", testVirk)
+	if len(found) != 2 || found[0].DateOfWork != "2026-01-05" || found[0].CPRNavn != "bob" || found[0].Version != 1 {
+		t.Errorf("migrated entries %+v", found)
+	}
+	decode(t, l.mustInvoke(l.admin, "migrateLogBogRepository"), &result)
+	if result.Migrated != 0 {
+		t.Errorf("second migration %+v", result)
+	}
+}
+
+func TestMigrateLogBogRepositorySkips(t *testing.T) {
+	l := newTestLedger(t, "")
+	l.add(testCPR, testVirk, "2026-01-05", "7")
+	l.mustInvoke(l.employer, "updateLogBog", testCPR, testVirk, "2026-01-05", `{"NoOfHours":"8"}`)
+	l.add(testCPR, testVirk, "2026-01-06", "7")
+	l.mustInvoke(l.admin, "retractLogBog", testCPR, testVirk, "2026-01-06", "wrong", "hard")
+
+	legacy := []SKATEmployee{
+		{CPRNum: 101901234, VirkNum: 12345678, CPRNavn: "Bob", DateOfWork: "2026-01-05", NoOfHours: 3},
+		{CPRNum: 101901234, VirkNum: 12345678, CPRNavn: "Bob", DateOfWork: "2026-01-06", NoOfHours: 4},
+		{CPRNum: 101901234, VirkNum: 12345678, CPRNavn: "Bob", DateOfWork: "some monday", NoOfHours: 5},
+	}
+	repository, _ := json.Marshal(SKATEmployeeRepository{EmployeeList: legacy})
+	l.mock.MockTransactionStart("seed")
+	l.mock.PutState(logBogRepositoryKey, repository)
+	for _, employee := range legacy {
+		record, _ := json.Marshal(employee)
+		l.mock.PutState(strconv.Itoa(employee.CPRNum)+"_"+strconv.Itoa(employee.VirkNum)+"_"+employee.DateOfWork, record)
+	}
+	l.mock.MockTransactionEnd("seed")
+	history := string(l.mock.State[logBogHistoryPrefix+"101901234_12345678_2026-01-05_00000001"])
+
+	var result repositoryMigration
+	decode(t, l.mustInvoke(l.admin, "migrateLogBogRepository"), &result)
+	if result.Migrated != 1 || len(result.Skipped) != 2 ||
+		result.Skipped[0].Code != errCodeAlreadyExists || result.Skipped[0].EntryKey != logBogEntryPrefix+"101901234_12345678_2026-01-05" ||
+		result.Skipped[0].CPRNum != 101901234 || result.Skipped[0].VirkNum != 12345678 || result.Skipped[0].DateOfWork != "2026-01-05" ||
+		result.Skipped[1].Code != errCodeInvalidArgument || result.Skipped[1].LegacyKey != "101901234_12345678_some monday" ||
+		result.Skipped[1].DateOfWork != "some monday" || result.Skipped[1].EntryKey != "" {
+		t.Fatalf("migrated %+v", result)
+	}
+	l.checkIndexes()
+
+	// the existing entry and its history are left alone
+	found := l.search(testCPR, testVirk)
+	if len(found) != 2 || found[0].NoOfHours != 8 || found[0].Version != 2 {
+		t.Errorf("existing entry %+v", found)
+	}
+	if string(l.mock.State[logBogHistoryPrefix+"101901234_12345678_2026-01-05_00000001"]) != history {
+		t.Errorf("history of the existing entry rewritten")
+	}
+	// the deleted entry comes back as the next link of its chain
+	var versions []logBogVersion
+	decode(t, l.mustQuery(l.auditor, "historyLogBog", testCPR, testVirk, "2026-01-06"), &versions)
+	if len(versions) != 3 || versions[2].Operation != opMigrate || versions[2].Version != 3 || found[1].Version != 3 || found[1].NoOfHours != 4 {
+		t.Errorf("history of the deleted entry %+v, entry %+v", versions, found[1])
+	}
+
+	// skipped records stay in the repository, migrated ones leave it
+	var left SKATEmployeeRepository
+	decode(t, l.mock.State[logBogRepositoryKey], &left)
+	if len(left.EmployeeList) != 2 || left.EmployeeList[0].NoOfHours != 3 || left.EmployeeList[1].DateOfWork != "some monday" {
+		t.Errorf("repository left %+v", left)
+	}
+	if _, ok := l.mock.State["101901234_12345678_2026-01-06"]; ok {
+		t.Errorf("legacy key of the migrated record left")
+	}
+	if _, ok := l.mock.State["101901234_12345678_some monday"]; !ok {
+		t.Errorf("legacy key of the skipped record removed")
+	}
+}
+
+func TestAccessControl(t *testing.T) {
+	l := newTestLedger(t, "")
+	l.add(testCPR, testVirk, "2026-01-05", "7")
+	l.add(testCPR2, testVirk2, "2026-01-05", "7")
+	stranger := l.caller(map[string]string{identityAttribute: "nobody"})
+	otherEmployer := l.caller(map[string]string{roleAttribute: roleEmployer, identityAttribute: "globex"})
+
+	tests := []struct {
+		name    string
+		caller  *testStub
+		query   bool
+		args    []string
+		allowed bool
+	}{
+		{"no role", stranger, true, []string{"searchLogBog", "", testVirk}, false},
+		{"employer own virk", l.employer, false, []string{"addToLogBog", testCPR, testVirk, "n", "2026-01-06", "7"}, true},
+		{"employer other virk", l.employer, false, []string{"addToLogBog", testCPR, testVirk2, "n", "2026-01-06", "7"}, false},
+		{"unbound employer", otherEmployer, false, []string{"addToLogBog", testCPR, testVirk, "n", "2026-01-07", "7"}, false},
+		{"employer search without virk", l.employer, true, []string{"searchLogBog", testCPR, ""}, false},
+		{"employer search own virk", l.employer, true, []string{"searchLogBog", "", testVirk}, true},
+		{"employee own cpr", l.employee, true, []string{"searchLogBog", testCPR, ""}, true},
+		{"employee other cpr", l.employee, true, []string{"searchLogBog", testCPR2, ""}, false},
+		{"employee add", l.employee, false, []string{"addToLogBog", testCPR, testVirk, "n", "2026-01-08", "7"}, false},
+		{"employee report own cpr", l.employee, true, []string{"reportHours", "virk", "2026-01-01", "2026-01-31", testCPR}, true},
+		{"auditor search anything", l.auditor, true, []string{"searchLogBog", "", testVirk2}, true},
+		{"auditor add", l.auditor, false, []string{"addToLogBog", testCPR, testVirk, "n", "2026-01-09", "7"}, false},
+		{"employer bind", l.employer, false, []string{"bindVirkNum", "acme", testVirk2}, false},
+		{"employer erase", l.employer, false, []string{"eraseSubject", testCPR, "case"}, false},
+		{"employer migrate", l.employer, false, []string{"migrateLogBog", "10"}, false},
+	}
+	for _, test := range tests {
+		var err error
+		if test.query {
+			_, err = l.query(test.caller, test.args[0], test.args[1:]...)
+		} else {
+			_, err = l.invoke(test.caller, test.args[0], test.args[1:]...)
+		}
+		if test.allowed && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if !test.allowed {
+			expectCode(t, test.name, err, errCodePermissionDenied, "")
+		}
+	}
+}
+
+func TestVirkBindings(t *testing.T) {
+	l := newTestLedger(t, "")
+	l.mustInvoke(l.admin, "bindVirkNum", "acme", testVirk2)
+	l.mustInvoke(l.admin, "bindVirkNum", "acme", testVirk2)
+	var binding virkBinding
+	decode(t, l.mustQuery(l.auditor, "getVirkBinding", "acme"), &binding)
+	if len(binding.VirkNums) != 2 || binding.VirkNums[0] != 12345678 || binding.VirkNums[1] != 87654321 {
+		t.Errorf("binding %+v", binding)
+	}
+	l.mustInvoke(l.admin, "unbindVirkNum", "acme", testVirk)
+	_, err := l.invoke(l.admin, "unbindVirkNum", "acme", testVirk)
+	expectCode(t, "unbind twice", err, errCodeNotFound, "")
+	_, err = l.invoke(l.employer, "addToLogBog", testCPR, testVirk, "n", "2026-01-05", "7")
+	expectCode(t, "after unbind", err, errCodePermissionDenied, "")
+	l.mustInvoke(l.admin, "unbindVirkNum", "acme", testVirk2)
+	if _, ok := l.mock.State[logBogBindingPrefix+"acme"]; ok {
+		t.Errorf("empty binding kept")
+	}
+}
+
+const testKeyMetadata = `{"logBogKey":"MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE="}`
+
+func TestPseudonymise(t *testing.T) {
+	l := newTestLedger(t, `{"pseudonymise":true}`)
+	_, err := l.invoke(l.admin, "addToLogBog", testCPR, testVirk, "Bob", "2026-01-05", "7")
+	expectCode(t, "without key", err, errCodeKeyRequired, "")
+
+	l.admin.metadata = []byte(testKeyMetadata)
+	l.add(testCPR, testVirk, "2026-01-05", "7")
+	l.mustInvoke(l.admin, "updateLogBog", testCPR, testVirk, "2026-01-05", `{"CPRNavn":"Robert"}`)
+	_, err = l.invoke(l.admin, "addToLogBog", testCPR, testVirk, "Bob", "2026-01-05", "7")
+	expectCode(t, "duplicate add", err, errCodeAlreadyExists, "")
+	if strings.Contains(err.Error(), "101901234") {
+		t.Errorf("clear CPR in %v", err)
+	}
+	for key, value := range l.mock.State {
+		if strings.Contains(key, "101901234") || strings.Contains(string(value), "101901234") || strings.Contains(string(value), "robert") {
+			t.Errorf("clear personal data in %s: %s", key, value)
+		}
+	}
+	l.checkIndexes()
+
+	found := l.search(testCPR, "")
+	if len(found) != 1 || found[0].CPRNum != 101901234 || found[0].CPRNavn != "robert" {
+		t.Errorf("search with key %+v", found)
+	}
+	var sealed []SKATEmployee
+	decode(t, l.mustQuery(l.auditor, "searchLogBog", "", testVirk), &sealed)
+	if len(sealed) != 1 || sealed[0].CPRNum != 0 || len(sealed[0].CPRNavnEnc) == 0 || sealed[0].CPRHash != found[0].CPRHash {
+		t.Errorf("search without key %+v", sealed)
+	}
+	_, err = l.query(l.auditor, "searchLogBog", testCPR, "")
+	expectCode(t, "search by cpr without key", err, errCodeKeyRequired, "")
+}
+
+// testOtherKeyMetadata is a well-formed logBogKey other than testKeyMetadata's.
+const testOtherKeyMetadata = `{"logBogKey":"OTg3NjU0MzIxMDk4NzY1NDMyMTA5ODc2NTQzMjEwOTg="}`
+
+func TestPseudonymiseKeyCheck(t *testing.T) {
+	l := newTestLedger(t, `{"pseudonymise":true}`)
+	if config, _ := getLogBogConfig(l.admin); len(config.KeyCheck) != 0 {
+		t.Errorf("key check before any key %q", config.KeyCheck)
+	}
+	l.admin.metadata = []byte(testKeyMetadata)
+	l.add(testCPR, testVirk, "2026-01-05", "7")
+	config, _ := getLogBogConfig(l.admin)
+	if len(config.KeyCheck) != 64 || strings.Contains(testKeyMetadata, config.KeyCheck) {
+		t.Fatalf("key check %q", config.KeyCheck)
+	}
+
+	other := l.caller(l.admin.attrs)
+	other.metadata = []byte(testOtherKeyMetadata)
+	tests := []struct {
+		function string
+		args     []string
+	}{
+		{"addToLogBog", []string{testCPR, testVirk, "Bob", "2026-01-05", "7"}},
+		{"upsertLogBog", []string{testCPR, testVirk, "Bob", "2026-01-06", "7"}},
+		{"updateLogBog", []string{testCPR, testVirk, "2026-01-05", `{"NoOfHours":6}`}},
+		{"eraseSubject", []string{testCPR, "case-1"}},
+		{"searchLogBog", []string{testCPR, ""}},
+		{"init", []string{"again", `{"pseudonymise":true}`}},
+	}
+	for _, test := range tests {
+		before := l.snapshot()
+		_, err := l.invoke(other, test.function, test.args...)
+		if isLogBogError(err, errCodeUnknownFunction) {
+			_, err = l.query(other, test.function, test.args...)
+		}
+		expectCode(t, test.function, err, errCodeKeyMismatch, logBogKeyMetadataField)
+		l.expectUnchanged(before, test.function)
+	}
+
+	// Init keeps the key check, and cannot be talked into another one through the config
+	l.mustInvoke(l.admin, "init", "again", `{"pseudonymise":true,"keyCheck":"00"}`)
+	if after, _ := getLogBogConfig(l.admin); after.KeyCheck != config.KeyCheck {
+		t.Errorf("key check after init %q", after.KeyCheck)
+	}
+	found := l.search(testCPR, "")
+	if len(found) != 1 || found[0].CPRNum != 101901234 {
+		t.Errorf("search with the right key %+v", found)
+	}
+
+	// a deployment without entries takes the key Init is called with
+	fresh := newTestLedger(t, "")
+	other = fresh.caller(fresh.admin.attrs)
+	other.metadata = []byte(testOtherKeyMetadata)
+	fresh.mustInvoke(other, "init", "hello", `{"pseudonymise":true}`)
+	fresh.admin.metadata = []byte(testKeyMetadata)
+	_, err := fresh.invoke(fresh.admin, "addToLogBog", testCPR, testVirk, "Bob", "2026-01-05", "7")
+	expectCode(t, "add after init with other key", err, errCodeKeyMismatch, logBogKeyMetadataField)
+}
+
+func TestEraseSubject(t *testing.T) {
+	l := newTestLedger(t, "")
+	l.mustInvoke(l.admin, "addToLogBog", testCPR, testVirk, "Bob", "2026-01-05", "7", "sick child")
+	l.add(testCPR, testVirk2, "2026-01-06", "5")
+	l.mustInvoke(l.admin, "retractLogBog", testCPR, testVirk2, "2026-01-06", "wrong")
+	l.add(testCPR2, testVirk, "2026-01-05", "4")
+
+	var receipt anonymisationReceipt
+	decode(t, l.mustInvoke(l.admin, "eraseSubject", testCPR, "case-17"), &receipt)
+	if receipt.Entries != 2 || receipt.HistoryVersions != 3 || receipt.Reference != "case-17" || len(receipt.VirkNums) != 2 || receipt.Scope != anonymisationScope {
+		t.Errorf("receipt %+v", receipt)
+	}
+	if _, ok := l.mock.State[logBogErasurePrefix+receipt.TxID]; !ok {
+		t.Errorf("receipt not stored")
+	}
+	for key, value := range l.mock.State {
+		if strings.Contains(key, "101901234") || strings.Contains(string(value), "101901234") || strings.Contains(string(value), "sick child") {
+			t.Errorf("personal data left in %s: %s", key, value)
+		}
+	}
+	l.checkIndexes()
+
+	var report hoursReport
+	decode(t, l.mustQuery(l.admin, "reportHours", "virk", "2026-01-01", "2026-01-31"), &report)
+	if len(report.Groups) != 1 || report.Groups[0].TotalHours != 11 {
+		t.Errorf("aggregates after erasure %+v", report.Groups)
+	}
+}
+
+func TestReportHours(t *testing.T) {
+	l := newTestLedger(t, "")
+	l.add(testCPR, testVirk, "2026-01-05", "7,5")
+	l.add(testCPR, testVirk, "2026-01-06", "8")
+	l.add(testCPR, testVirk2, "2026-01-13", "4")
+	l.add(testCPR2, testVirk, "2026-01-13", "6")
+	l.add(testCPR2, testVirk, "2026-01-14", "3")
+	l.mustInvoke(l.admin, "retractLogBog", testCPR2, testVirk, "2026-01-14", "r")
+
+	tests := []struct {
+		args   []string
+		groups string
+	}{
+		{[]string{"", "2026-01-01", "2026-01-31"}, "25.5/4"},
+		{[]string{"cpr", "2026-01-01", "2026-01-31"}, "101901234:19.5/3 202851234:6/1"},
+		{[]string{"virk,week", "2026-01-01", "2026-01-31"}, "12345678 2026-W02:15.5/2 12345678 2026-W03:6/1 87654321 2026-W03:4/1"},
+		{[]string{"month", "2026-01-06", "2026-01-31", testCPR}, "2026-01:12/2"},
+		{[]string{"cpr,virk,day", "2026-01-13", "2026-01-13", "", testVirk}, "202851234 12345678 2026-01-13:6/1"},
+	}
+	for _, test := range tests {
+		var report hoursReport
+		decode(t, l.mustQuery(l.admin, "reportHours", test.args...), &report)
+		groups := []string{}
+		for _, group := range report.Groups {
+			name := []string{}
+			if group.CPRNum != 0 {
+				name = append(name, strconv.Itoa(group.CPRNum))
+			}
+			if group.VirkNum != 0 {
+				name = append(name, strconv.Itoa(group.VirkNum))
+			}
+			if len(group.Period) > 0 {
+				name = append(name, group.Period)
+			}
+			prefix := strings.Join(name, " ")
+			if len(prefix) > 0 {
+				prefix += ":"
+			}
+			groups = append(groups, prefix+formatHours(group.TotalHours)+"/"+strconv.Itoa(group.Entries))
+		}
+		if strings.Join(groups, " ") != test.groups {
+			t.Errorf("%v: got %s, want %s", test.args, strings.Join(groups, " "), test.groups)
+		}
+	}
+}
+
+func TestWorkingTimeRules(t *testing.T) {
+	l := newTestLedger(t, `{"workingTimeRules":"reject","maxWeeklyAverageHours":20,"referencePeriodWeeks":1}`)
+	l.add(testCPR, testVirk, "2026-01-05", "8")
+	_, err := l.invoke(l.admin, "addToLogBog", testCPR, testVirk2, "n", "2026-01-05", "6")
+	expectCode(t, "daily maximum across VirkNums", err, errCodeRuleViolation, "NoOfHours")
+	_, err = l.invoke(l.admin, "addToLogBog", testCPR, testVirk2, "n", "2026-01-07", "13")
+	expectCode(t, "weekly average", err, errCodeRuleViolation, "NoOfHours")
+	l.add(testCPR, testVirk2, "2026-01-12", "12")
+	l.add(testCPR2, testVirk2, "2026-01-05", "12")
+
+	l = newTestLedger(t, `{"workingTimeRules":"flag","minRestHours":12}`)
+	l.add(testCPR, testVirk, "2026-01-05", "12,5")
+	found := l.search(testCPR, "")
+	if len(found) != 1 || len(found[0].RuleViolations) != 1 || !strings.Contains(found[0].RuleViolations[0], "minimum rest") {
+		t.Errorf("flagged %+v", found)
+	}
+	l.mustInvoke(l.admin, "updateLogBog", testCPR, testVirk, "2026-01-05", `{"NoOfHours":8}`)
+	if found = l.search(testCPR, ""); len(found[0].RuleViolations) != 0 {
+		t.Errorf("flag kept after fix %+v", found)
+	}
+}
+
+func TestSchemaUpgradeAndMigrateLogBog(t *testing.T) {
+	l := newTestLedger(t, "")
+	l.mock.MockTransactionStart("seed")
+	for day := 1; day <= 5; day++ {
+		// a record as the baseline chaincode stored it, with whole hours
+		employee := SKATEmployee{CPRNum: 101901234, VirkNum: 12345678, DateOfWork: "2026-01-0" + strconv.Itoa(day)}
+		record := []byte(`{"CPRNum":101901234,"VirkNum":12345678,"CPRNavn":"bob","DOW":"` + employee.DateOfWork + `","NoOfHours":7}`)
+		l.mock.PutState(logBogEntryKey(employee), record)
+		l.mock.PutState(logBogVirkIndexKey(employee), []byte(logBogEntryKey(employee)))
+		l.mock.PutState(logBogDateIndexKey(employee), []byte(logBogEntryKey(employee)))
+	}
+	l.mock.MockTransactionEnd("seed")
+
+	for _, employee := range l.search(testCPR, "") {
+		if employee.SchemaVersion != logBogSchemaVersion || employee.CPRNavn != "bob" || employee.NoOfHours != 7 {
+			t.Errorf("not upgraded on read: %+v", employee)
+		}
+	}
+
+	cursor := ""
+	migrated := 0
+	for batches := 1; ; batches++ {
+		var batch migrateBatch
+		decode(t, l.mustInvoke(l.admin, "migrateLogBog", "2", cursor), &batch)
+		migrated += batch.Migrated
+		if cursor = batch.NextCursor; len(cursor) == 0 {
+			break
+		}
+		if batches > 5 {
+			t.Fatal("migrateLogBog does not finish")
+		}
+	}
+	if migrated != 5 {
+		t.Errorf("migrated %d", migrated)
+	}
+	for key, value := range l.mock.State {
+		if strings.HasPrefix(key, logBogEntryPrefix) && !strings.Contains(string(value), `"SchemaVersion":`+strconv.Itoa(logBogSchemaVersion)) {
+			t.Errorf("%s not rewritten: %s", key, value)
+		}
+	}
+
+	for _, after := range []string{"LogBog", logBogConfigKey, "LogBog_entry", logBogVirkIndexPrefix, "zzz"} {
+		before := l.snapshot()
+		_, err := l.invoke(l.admin, "migrateLogBog", "1", encodePageToken(after))
+		expectCode(t, "cursor "+after, err, errCodeInvalidArgument, "cursor")
+		l.expectUnchanged(before, "cursor "+after)
+	}
+
+	l.mock.MockTransactionStart("seed")
+	l.mock.PutState(logBogEntryPrefix+"101901234_12345678_2026-01-09", []byte(`{"SchemaVersion":99}`))
+	l.mock.MockTransactionEnd("seed")
+	_, err := l.query(l.admin, "searchLogBog", testCPR, "")
+	expectCode(t, "newer schema", err, errCodeCorruptState, "")
+}
+
+func TestEvents(t *testing.T) {
+	l := newTestLedger(t, "")
+	tests := []struct {
+		function  string
+		args      []string
+		operation string
+	}{
+		{"addToLogBog", []string{testCPR, testVirk, "n", "2026-01-05", "7"}, opAdd},
+		{"upsertLogBog", []string{testCPR, testVirk, "n", "2026-01-05", "8"}, opUpsert},
+		{"updateLogBog", []string{testCPR, testVirk, "2026-01-05", "c"}, opUpdate},
+		{"retractLogBog", []string{testCPR, testVirk, "2026-01-05", "r"}, opRetract},
+		{"retractLogBog", []string{testCPR, testVirk, "2026-01-05", "r", "hard"}, opDelete},
+	}
+	for _, test := range tests {
+		l.mustInvoke(l.admin, test.function, test.args...)
+		if l.admin.eventName != logBogChangedEvent {
+			t.Errorf("%s: event %q", test.operation, l.admin.eventName)
+			continue
+		}
+		var event logBogEvent
+		decode(t, l.admin.eventPayload, &event)
+		if len(event.Changes) != 1 || event.Changes[0].Operation != test.operation || event.Changes[0].VirkNum != 12345678 {
+			t.Errorf("%s: event %s", test.operation, l.admin.eventPayload)
+		}
+		if strings.Contains(string(l.admin.eventPayload), testCPR[1:]) {
+			t.Errorf("%s: event exposes the CPR number: %s", test.operation, l.admin.eventPayload)
+		}
+	}
+	_, err := l.invoke(l.admin, "addToLogBog", testCPR, testVirk, "n", "2026-01-05", "x")
+	if err == nil || l.admin.eventName != "" {
+		t.Errorf("failed add set event %q", l.admin.eventName)
+	}
+}
+
+func TestBulkAddToLogBog(t *testing.T) {
+	csvBatch := "CPRNum;VirkNum;CPRNavn;DOW;NoOfHours;Comments\n" +
+		"0101901234;12345678;Bob Jensen;2026-01-05;7,5;\n" +
+		"0101901234;12345678;Bob Jensen;2026-01-06;;\n" +
+		"0202851234;12345678;Al;06-01-2026;8;\"late; train\"\n"
+	jsonBatch := `[{"CPRNum":101901234,"VirkNum":12345678,"CPRNavn":"Bob","DOW":"2026-01-05","NoOfHours":6},` +
+		`{"cprnum":"0101901234","virknum":"12345678","cprnavn":"Bob","dow":"2026-01-07","starttime":"08:00","endtime":"12:00"}]`
+
+	tests := []struct {
+		name     string
+		caller   string
+		args     []string
+		code     string
+		rows     string
+		hours    string
+		replaced int
+	}{
+		{"csv with a bad row", "admin", []string{csvBatch}, errCodeBatchRejected, "3:NoOfHours", "7", 0},
+		{"employer outside its VirkNum", "employer", []string{strings.Replace(csvBatch, ";;\n", ";7;\n", 1) + "0101901234;87654321;Bob;2026-01-07;1;\n"}, errCodeBatchRejected, "5:", "7", 0},
+		{"existing and repeated rows", "admin", []string{"CPRNum,VirkNum,CPRNavn,DOW,NoOfHours\n0101901234,12345678,Bob,2026-01-04,1\n0101901234,12345678,Bob,2026-01-08,1\n0101901234,12345678,Bob,08-01-2026,2\n"}, errCodeBatchRejected, "2: 4:", "7", 0},
+		{"csv", "employer", []string{strings.Replace(csvBatch, ";;\n", ";7;\n", 1)}, "", "", "7,7.5,7,8", 0},
+		{"json without upsert", "admin", []string{jsonBatch}, errCodeBatchRejected, "1:", "7,7.5,7,8", 0},
+		{"json upsert", "admin", []string{jsonBatch, "upsert"}, "", "", "7,6,7,4,8", 1},
+		{"unknown column", "admin", []string{"CPRNum,VirkNum,CPRNavn,DOW,Hours\n"}, errCodeInvalidArgument, "", "7,6,7,4,8", 0},
+		{"missing column", "admin", []string{`[{"CPRNum":"0101901234"}]`}, errCodeInvalidArgument, "", "7,6,7,4,8", 0},
+		{"header only", "admin", []string{"CPRNum,VirkNum,CPRNavn,DOW\n"}, errCodeInvalidArgument, "", "7,6,7,4,8", 0},
+		{"bad mode", "admin", []string{jsonBatch, "replace"}, errCodeInvalidArgument, "", "7,6,7,4,8", 0},
+	}
+	l := newTestLedger(t, "")
+	l.add(testCPR, testVirk, "2026-01-04", "7")
+	for _, test := range tests {
+		caller := l.admin
+		if test.caller == "employer" {
+			caller = l.employer
+		}
+		before := l.snapshot()
+		checked, checkErr := l.query(caller, "checkBulkAddToLogBog", test.args...)
+		l.expectUnchanged(before, test.name+" checked")
+		out, err := l.invoke(caller, "bulkAddToLogBog", test.args...)
+		if fmt.Sprint(checkErr) != fmt.Sprint(err) || string(checked) != string(out) {
+			t.Errorf("%s: checked %s %v, stored %s %v", test.name, checked, checkErr, out, err)
+		}
+		if len(test.code) > 0 {
+			expectCode(t, test.name, err, test.code, "")
+			l.expectUnchanged(before, test.name)
+			if logBogErr, ok := err.(*logBogError); ok && len(test.rows) > 0 {
+				rows := ""
+				for _, row := range logBogErr.Rows {
+					rows += strconv.Itoa(row.Row) + ":" + row.Field + " "
+				}
+				if strings.TrimSpace(rows) != test.rows {
+					t.Errorf("%s: rows %q, want %q", test.name, rows, test.rows)
+				}
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else {
+			var result bulkResult
+			decode(t, out, &result)
+			if result.Replaced != test.replaced || result.Added+result.Replaced != result.Rows {
+				t.Errorf("%s: result %+v", test.name, result)
+			}
+			var event logBogEvent
+			decode(t, caller.eventPayload, &event)
+			if len(event.Changes) != result.Rows {
+				t.Errorf("%s: event lists %d changes", test.name, len(event.Changes))
+			}
+		}
+		hours := []string{}
+		for _, employee := range l.search("", testVirk) {
+			hours = append(hours, formatHours(employee.NoOfHours))
+		}
+		if strings.Join(hours, ",") != test.hours {
+			t.Errorf("%s: stored hours %s, want %s", test.name, strings.Join(hours, ","), test.hours)
+		}
+	}
+	l.checkIndexes()
+}
+
+// TestBulkAddToLogBogRules rejects a batch whose rows together break a
+// working-time rule; MockStub keeps the writes a peer would discard.
+func TestBulkAddToLogBogRules(t *testing.T) {
+	l := newTestLedger(t, `{"workingTimeRules":"reject"}`)
+	batch := "CPRNum,VirkNum,CPRNavn,DOW,NoOfHours\n" +
+		"0101901234,12345678,Bob,2026-01-05,8\n0101901234,87654321,Bob,2026-01-05,8\n"
+	_, checkErr := l.query(l.admin, "checkBulkAddToLogBog", batch)
+	_, err := l.invoke(l.admin, "bulkAddToLogBog", batch)
+	for name, err := range map[string]error{"checked": checkErr, "stored": err} {
+		expectCode(t, name+" daily maximum across rows", err, errCodeBatchRejected, "")
+		if logBogErr, ok := err.(*logBogError); ok && (len(logBogErr.Rows) != 1 || logBogErr.Rows[0].Row != 3 || logBogErr.Rows[0].Code != errCodeRuleViolation) {
+			t.Errorf("%s rows %+v", name, logBogErr.Rows)
+		}
+	}
+}
+
+func TestExportLogBog(t *testing.T) {
+	l := newTestLedger(t, "")
+	l.mustInvoke(l.admin, "addToLogBog", testCPR2, testVirk, "Al Øster", "2026-01-06", "8", "late, \"train\"")
+	l.add(testCPR, testVirk, "2026-01-05", "7,5")
+	l.add(testCPR, testVirk, "2026-01-20", "6")
+	l.add(testCPR, testVirk2, "2026-01-05", "3")
+	l.add(testCPR, testVirk, "2026-02-01", "1")
+	l.mustInvoke(l.admin, "retractLogBog", testCPR, testVirk, "2026-01-20", "wrong day")
+
+	tests := []struct {
+		name   string
+		args   []string
+		data   string
+		masked bool
+	}{
+		{"csv", []string{testVirk, "2026-01-01", "2026-01-31", "csv"},
+			strings.Join(exportColumns, ",") + "\n" +
+				"12345678,2026-01-05,0101901234,,navn,7.5,,,,false,,,,1\n" +
+				"12345678,2026-01-20,0101901234,,navn,6,,,,true,wrong day," + testTxTime.Format(time.RFC3339) + ",,2\n" +
+				"12345678,2026-01-06,0202851234,,al øster,8,,,\"late, \"\"train\"\"\",false,,,,1\n", false},
+		{"masked csv", []string{testVirk, "2026-01-06", "2026-01-20", "csv", "true"},
+			strings.Join(exportColumns, ",") + "\n" +
+				"12345678,2026-01-20,010190****,,n.,6,,,,true,," + testTxTime.Format(time.RFC3339) + ",,2\n" +
+				"12345678,2026-01-06,020285****,,a.ø.,8,,,,false,,,,1\n", true},
+		{"jsonl", []string{testVirk, "2026-01-01", "2026-01-05", "jsonl"},
+			`{"VirkNum":12345678,"DOW":"2026-01-05","CPRNum":"0101901234","CPRHash":"","CPRNavn":"navn","NoOfHours":7.5,"StartTime":"","EndTime":"",` +
+				`"Comments":"","Retracted":false,"RetractReason":"","RetractedAt":"","RuleViolations":[],"Version":1}` + "\n", false},
+		{"empty", []string{testVirk2, "2026-03-01", "2026-03-31", "jsonl"}, "", false},
+	}
+	for _, test := range tests {
+		var export logBogExport
+		decode(t, l.mustQuery(l.auditor, "exportLogBog", test.args...), &export)
+		if export.Data != test.data {
+			t.Errorf("%s: data\n%s\nwant\n%s", test.name, export.Data, test.data)
+		}
+		digest := sha256.Sum256([]byte(export.Data))
+		manifest := export.Manifest
+		if manifest.SHA256 != hex.EncodeToString(digest[:]) || manifest.Rows != strings.Count(test.data, "\n")-strings.Count(test.args[3], "csv") ||
+			manifest.Format != test.args[3] || manifest.Masked != test.masked || manifest.From != test.args[1] || len(manifest.Columns) != len(exportColumns) {
+			t.Errorf("%s: manifest %+v", test.name, manifest)
+		}
+	}
+
+	// a pseudonymised ledger's CPRHash is left out of a masked export too
+	sealed := newTestLedger(t, `{"pseudonymise":true}`)
+	sealed.admin.metadata = []byte(testKeyMetadata)
+	sealed.add(testCPR, testVirk, "2026-01-05", "7")
+	for _, mask := range []string{"false", "true"} {
+		var export logBogExport
+		decode(t, sealed.mustQuery(sealed.admin, "exportLogBog", testVirk, "2026-01-01", "2026-01-31", "jsonl", mask), &export)
+		var row exportRow
+		decode(t, []byte(export.Data), &row)
+		if (len(row.CPRHash) > 0) != (mask == "false") {
+			t.Errorf("mask %s: CPRHash %q", mask, row.CPRHash)
+		}
+	}
+
+	// the JSON Lines keys follow exportColumns
+	line, _ := json.Marshal(exportRow{})
+	keys := []string{}
+	for _, field := range strings.Split(strings.Trim(string(line), "{}"), ",") {
+		keys = append(keys, strings.Trim(strings.SplitN(field, ":", 2)[0], `"`))
+	}
+	if strings.Join(keys, ",") != strings.Join(exportColumns, ",") {
+		t.Errorf("JSON Lines keys %v", keys)
+	}
+	if len(exportRow{}.values()) != len(exportColumns) {
+		t.Errorf("CSV row has %d values", len(exportRow{}.values()))
+	}
+
+	_, err := l.query(l.employer, "exportLogBog", testVirk2, "2026-01-01", "2026-01-31", "csv")
+	expectCode(t, "employer other VirkNum", err, errCodePermissionDenied, "")
+	_, err = l.query(l.employee, "exportLogBog", testVirk, "2026-01-01", "2026-01-31", "csv")
+	expectCode(t, "employee", err, errCodePermissionDenied, "")
+	_, err = l.query(l.employer, "exportLogBog", testVirk, "2026-01-01", "2026-01-31", "xlsx")
+	expectCode(t, "format", err, errCodeInvalidArgument, "format")
+	_, err = l.query(l.employer, "exportLogBog", testVirk, "", "2026-01-31", "csv")
+	expectCode(t, "open range", err, errCodeInvalidArgument, "from")
+	l.mustQuery(l.employer, "exportLogBog", testVirk, "2026-01-01", "2026-01-31", "csv")
+}
@@ -0,0 +1,485 @@
+//go:build emulator
+// +build emulator
+
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Built with
+//
+//	go build -tags emulator -o logbog-emulator ./finished
+//
+// the chaincode becomes a stand-in for a fabric v0.6 peer and its membership
+// service. It hosts SimpleChaincode in-process and serves the peer's REST API:
+//
+//	POST   /registrar       {"enrollId": "...", "enrollSecret": "..."} logs a user in
+//	GET    /registrar/<id>  tells whether the user is logged in
+//	DELETE /registrar/<id>  logs the user out
+//	POST   /chaincode       JSON-RPC 2.0 deploy, invoke and query
+//
+// so the requests in LearnChaincodeREST.postman_collection.json run against
+// it unchanged. The users, their secrets and the certificate attributes their
+// transactions carry come from the users file, see emulator_users.json; a
+// user's enrollmentID attribute defaults to their enrollId. Metadata, such as
+// the logBogKey of a pseudonymised ledger, is passed as the base64 "metadata"
+// of the request's params, as on a peer.
+//
+// The world state of every chaincode deployed is kept in the state file and
+// written after every deploy and invoke that succeeds; a failed one leaves the
+// state as it was. As on a peer, a deploy with another path or other init args
+// starts a chaincode of its own next to the earlier ones. Unlike a peer,
+// invoke runs synchronously and returns the chaincode's error.
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// JSON-RPC error codes, as the peer returns them
+const (
+	rpcParseError      = -32700
+	rpcInvalidRequest  = -32600
+	rpcMethodNotFound  = -32601
+	rpcInvalidParams   = -32602
+	rpcDeploymentError = -32001
+	rpcInvocationError = -32002
+	rpcQueryError      = -32003
+)
+
+type emulatorUser struct {
+	EnrollSecret string            `json:"enrollSecret"`
+	Attributes   map[string]string `json:"attributes"`
+}
+
+// emulatorState is what the state file holds: the world state of each
+// chaincode by name. ChaincodeName and State are the one chaincode a state
+// file written before there could be several holds; they are read, never written.
+type emulatorState struct {
+	Chaincodes    map[string]map[string][]byte `json:"chaincodes"`
+	ChaincodeName string                       `json:"chaincodeName,omitempty"`
+	State         map[string][]byte            `json:"state,omitempty"`
+}
+
+type chaincodeSpec struct {
+	Type        int `json:"type"`
+	ChaincodeID struct {
+		Path string `json:"path"`
+		Name string `json:"name"`
+	} `json:"chaincodeID"`
+	CtorMsg struct {
+		Function string   `json:"function"`
+		Args     []string `json:"args"`
+	} `json:"ctorMsg"`
+	SecureContext string `json:"secureContext"`
+	Metadata      []byte `json:"metadata"`
+}
+
+type rpcRequest struct {
+	JSONRPC string           `json:"jsonrpc"`
+	Method  string           `json:"method"`
+	Params  *chaincodeSpec   `json:"params"`
+	ID      *json.RawMessage `json:"id"`
+}
+
+type rpcResult struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	Result  *rpcResult       `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+	ID      *json.RawMessage `json:"id"`
+}
+
+// callerStub is what one transaction sees: the shared MockStub plus the
+// caller's certificate attributes and metadata, and the transaction time.
+type callerStub struct {
+	*shim.MockStub
+	attributes map[string]string
+	metadata   []byte
+	txTime     time.Time
+}
+
+func (s *callerStub) ReadCertAttribute(name string) ([]byte, error) {
+	value, ok := s.attributes[name]
+	if !ok {
+		return nil, errors.New("certificate has no attribute " + name)
+	}
+	return []byte(value), nil
+}
+
+func (s *callerStub) GetCallerCertificate() ([]byte, error) {
+	return nil, nil
+}
+
+func (s *callerStub) GetCallerMetadata() ([]byte, error) {
+	return s.metadata, nil
+}
+
+func (s *callerStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: s.txTime.Unix(), Nanos: int32(s.txTime.Nanosecond())}, nil
+}
+
+func (s *callerStub) SetEvent(name string, payload []byte) error {
+	fmt.Println("event " + name + ": " + string(payload))
+	return nil
+}
+
+type emulator struct {
+	sync.Mutex
+	cc        *SimpleChaincode
+	stubs     map[string]*shim.MockStub
+	statePath string
+	users     map[string]emulatorUser
+	loggedIn  map[string]bool
+}
+
+func newEmulator(statePath string, usersPath string) (*emulator, error) {
+	e := &emulator{cc: new(SimpleChaincode), statePath: statePath, loggedIn: map[string]bool{}}
+
+	usersJsonAsBytes, err := ioutil.ReadFile(usersPath)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(usersJsonAsBytes, &e.users)
+	if err != nil {
+		return nil, errors.New(usersPath + ": " + err.Error())
+	}
+	if len(e.users) == 0 {
+		return nil, errors.New(usersPath + " has no users")
+	}
+
+	var saved emulatorState
+	stateJsonAsBytes, err := ioutil.ReadFile(statePath)
+	if err == nil {
+		err = json.Unmarshal(stateJsonAsBytes, &saved)
+		if err != nil {
+			return nil, errors.New(statePath + ": " + err.Error())
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	e.restore(saved)
+	return e, nil
+}
+
+// restore replaces the world state of every chaincode by saved.
+func (e *emulator) restore(saved emulatorState) {
+	e.stubs = map[string]*shim.MockStub{}
+	for name, state := range saved.Chaincodes {
+		e.stubs[name] = e.newStub(state)
+	}
+	if len(saved.ChaincodeName) > 0 && e.stubs[saved.ChaincodeName] == nil {
+		e.stubs[saved.ChaincodeName] = e.newStub(saved.State)
+	}
+}
+
+// newStub returns a MockStub holding state.
+func (e *emulator) newStub(state map[string][]byte) *shim.MockStub {
+	stub := shim.NewMockStub("logbog", e.cc)
+	stub.MockTransactionStart("restore")
+	for key, value := range state {
+		stub.PutState(key, value)
+	}
+	stub.MockTransactionEnd("restore")
+	return stub
+}
+
+func (e *emulator) snapshot() emulatorState {
+	saved := emulatorState{Chaincodes: map[string]map[string][]byte{}}
+	for name, stub := range e.stubs {
+		state := map[string][]byte{}
+		for key, value := range stub.State {
+			state[key] = value
+		}
+		saved.Chaincodes[name] = state
+	}
+	return saved
+}
+
+// save writes the world state to a temporary file first, so a crash never
+// leaves a half-written state file behind.
+func (e *emulator) save() error {
+	jsonAsBytes, err := json.MarshalIndent(e.snapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(e.statePath+".tmp", jsonAsBytes, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(e.statePath+".tmp", e.statePath)
+}
+
+// caller builds the stub for a request to the chaincode on stub made as its
+// secureContext user.
+func (e *emulator) caller(stub *shim.MockStub, spec *chaincodeSpec) (*callerStub, error) {
+	user, ok := e.users[spec.SecureContext]
+	if !ok || !e.loggedIn[spec.SecureContext] {
+		return nil, errors.New("User " + spec.SecureContext + " not logged in. Use the '/registrar' endpoint to log in.")
+	}
+	attributes := map[string]string{identityAttribute: spec.SecureContext}
+	for name, value := range user.Attributes {
+		attributes[name] = value
+	}
+	return &callerStub{MockStub: stub, attributes: attributes, metadata: spec.Metadata, txTime: time.Now().UTC()}, nil
+}
+
+// transact runs the chaincode on stub in a transaction of its own and saves
+// the world state if it succeeds, or rolls it back if it fails.
+func (e *emulator) transact(stub *shim.MockStub, spec *chaincodeSpec, run func(stub shim.ChaincodeStubInterface) ([]byte, error)) (string, []byte, error) {
+	before := e.snapshot()
+	txID, err := newTxID()
+	if err != nil {
+		return "", nil, err
+	}
+	caller, err := e.caller(stub, spec)
+	if err != nil {
+		return "", nil, err
+	}
+	stub.MockTransactionStart(txID)
+	out, err := run(caller)
+	stub.MockTransactionEnd(txID)
+	if err == nil {
+		err = e.save()
+	}
+	if err != nil {
+		e.restore(before)
+		return "", nil, err
+	}
+	return txID, out, nil
+}
+
+// ============================================================================================================================
+// Deploy - run Init. Deploying another path, or other init args, starts a new chaincode next to the others
+// ============================================================================================================================
+func (e *emulator) deploy(spec *chaincodeSpec) (string, *rpcError) {
+	if len(spec.ChaincodeID.Path) == 0 {
+		return "", &rpcError{Code: rpcInvalidParams, Message: "Invalid params", Data: "chaincodeID.path is required"}
+	}
+	ctorJsonAsBytes, _ := json.Marshal(spec.CtorMsg)
+	hash := sha512.Sum512(append([]byte(spec.ChaincodeID.Path), ctorJsonAsBytes...))
+	name := hex.EncodeToString(hash[:])
+
+	previous := e.snapshot()
+	stub := e.stubs[name]
+	if stub == nil {
+		stub = e.newStub(nil)
+		e.stubs[name] = stub
+	}
+	_, _, err := e.transact(stub, spec, func(stub shim.ChaincodeStubInterface) ([]byte, error) {
+		return e.cc.Init(stub, spec.CtorMsg.Function, spec.CtorMsg.Args)
+	})
+	if err != nil {
+		e.restore(previous)
+		return "", &rpcError{Code: rpcDeploymentError, Message: "Deployment failure", Data: err.Error()}
+	}
+	fmt.Println("deployed " + spec.ChaincodeID.Path + " as " + name)
+	return name, nil
+}
+
+// ============================================================================================================================
+// Invoke - run Invoke and return the transaction id
+// ============================================================================================================================
+func (e *emulator) invoke(spec *chaincodeSpec) (string, *rpcError) {
+	stub, err := e.deployed(spec)
+	if err != nil {
+		return "", &rpcError{Code: rpcInvocationError, Message: "Invocation failure", Data: err.Error()}
+	}
+	txID, _, err := e.transact(stub, spec, func(stub shim.ChaincodeStubInterface) ([]byte, error) {
+		return e.cc.Invoke(stub, spec.CtorMsg.Function, spec.CtorMsg.Args)
+	})
+	if err != nil {
+		return "", &rpcError{Code: rpcInvocationError, Message: "Invocation failure", Data: err.Error()}
+	}
+	return txID, nil
+}
+
+// ============================================================================================================================
+// Query - run Query outside any transaction and return its result
+// ============================================================================================================================
+func (e *emulator) query(spec *chaincodeSpec) (string, *rpcError) {
+	stub, err := e.deployed(spec)
+	if err != nil {
+		return "", &rpcError{Code: rpcQueryError, Message: "Query failure", Data: err.Error()}
+	}
+	caller, err := e.caller(stub, spec)
+	if err != nil {
+		return "", &rpcError{Code: rpcQueryError, Message: "Query failure", Data: err.Error()}
+	}
+	out, err := e.cc.Query(caller, spec.CtorMsg.Function, spec.CtorMsg.Args)
+	if err != nil {
+		return "", &rpcError{Code: rpcQueryError, Message: "Query failure", Data: err.Error()}
+	}
+	return string(out), nil
+}
+
+// deployed returns the stub of the chaincode spec names.
+func (e *emulator) deployed(spec *chaincodeSpec) (*shim.MockStub, error) {
+	if len(e.stubs) == 0 {
+		return nil, errors.New("no chaincode is deployed")
+	}
+	stub := e.stubs[spec.ChaincodeID.Name]
+	if stub == nil {
+		return nil, errors.New("chaincode " + spec.ChaincodeID.Name + " is not deployed")
+	}
+	return stub, nil
+}
+
+func (e *emulator) serveChaincode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"Error": "Use POST to call /chaincode"})
+		return
+	}
+	var request rpcRequest
+	response := rpcResponse{JSONRPC: "2.0"}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		response.Error = &rpcError{Code: rpcParseError, Message: "Parse error", Data: err.Error()}
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+	response.ID = request.ID
+	if request.JSONRPC != "2.0" {
+		response.Error = &rpcError{Code: rpcInvalidRequest, Message: "Invalid request", Data: "jsonrpc must be 2.0"}
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+	if request.Params == nil {
+		response.Error = &rpcError{Code: rpcInvalidParams, Message: "Invalid params", Data: "params are required"}
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	fmt.Println(request.Method + " is running " + request.Params.CtorMsg.Function + " as " + request.Params.SecureContext)
+	var message string
+	switch request.Method {
+	case "deploy":
+		message, response.Error = e.deploy(request.Params)
+	case "invoke":
+		message, response.Error = e.invoke(request.Params)
+	case "query":
+		message, response.Error = e.query(request.Params)
+	default:
+		response.Error = &rpcError{Code: rpcMethodNotFound, Message: "Method not found", Data: "The requested method does not exist: " + request.Method}
+	}
+	if response.Error == nil {
+		response.Result = &rpcResult{Status: "OK", Message: message}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (e *emulator) serveRegistrar(w http.ResponseWriter, r *http.Request) {
+	e.Lock()
+	defer e.Unlock()
+
+	enrollID := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/registrar"), "/")
+	switch {
+	case r.Method == "POST" && len(enrollID) == 0:
+		var login struct {
+			EnrollID     string `json:"enrollId"`
+			EnrollSecret string `json:"enrollSecret"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&login)
+		if err != nil || len(login.EnrollID) == 0 || len(login.EnrollSecret) == 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"Error": "enrollId and enrollSecret are required"})
+			return
+		}
+		user, ok := e.users[login.EnrollID]
+		if !ok || user.EnrollSecret != login.EnrollSecret {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"Error": "Login error: unknown user or wrong secret for " + login.EnrollID})
+			return
+		}
+		e.loggedIn[login.EnrollID] = true
+		writeJSON(w, http.StatusOK, map[string]string{"OK": "Login successful for user '" + login.EnrollID + "'."})
+	case r.Method == "GET" && len(enrollID) > 0:
+		if !e.loggedIn[enrollID] {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"Error": "User " + enrollID + " must log in."})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"OK": "User " + enrollID + " is already logged in."})
+	case r.Method == "DELETE" && len(enrollID) > 0:
+		delete(e.loggedIn, enrollID)
+		writeJSON(w, http.StatusOK, map[string]string{"OK": "Deleted login token for user " + enrollID + "."})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"Error": "Use POST /registrar, GET or DELETE /registrar/<enrollId>"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// newTxID returns a random UUID, the form of a peer's transaction ids.
+func newTxID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func main() {
+	listen := flag.String("listen", ":7050", "address to serve the peer REST API on")
+	statePath := flag.String("state", "logbog_state.json", "file the world state is kept in")
+	usersPath := flag.String("users", "emulator_users.json", "file of the users, their secrets and certificate attributes")
+	flag.Parse()
+
+	e, err := newEmulator(*statePath, *usersPath)
+	if err != nil {
+		fmt.Printf("Error starting emulator: %s\n", err)
+		os.Exit(1)
+	}
+	http.HandleFunc("/chaincode", e.serveChaincode)
+	http.HandleFunc("/registrar", e.serveRegistrar)
+	http.HandleFunc("/registrar/", e.serveRegistrar)
+	fmt.Println("emulating a peer on " + *listen)
+	err = http.ListenAndServe(*listen, nil)
+	if err != nil {
+		fmt.Printf("Error serving: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,209 @@
+//go:build emulator
+// +build emulator
+
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPeer serves an emulator on a temporary state file.
+type testPeer struct {
+	t         *testing.T
+	server    *httptest.Server
+	statePath string
+	emulator  *emulator
+	name      string
+}
+
+func newTestPeer(t *testing.T, statePath string) *testPeer {
+	e, err := newEmulator(statePath, "emulator_users.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chaincode", e.serveChaincode)
+	mux.HandleFunc("/registrar", e.serveRegistrar)
+	mux.HandleFunc("/registrar/", e.serveRegistrar)
+	return &testPeer{t: t, server: httptest.NewServer(mux), statePath: statePath, emulator: e}
+}
+
+func (p *testPeer) post(path string, body string) (int, map[string]interface{}) {
+	resp, err := http.Post(p.server.URL+path, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var decoded map[string]interface{}
+	err = json.NewDecoder(resp.Body).Decode(&decoded)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return resp.StatusCode, decoded
+}
+
+func (p *testPeer) login(user, secret string) {
+	status, body := p.post("/registrar", `{"enrollId":"`+user+`","enrollSecret":"`+secret+`"}`)
+	if status != http.StatusOK {
+		p.t.Fatalf("login %s: %d %v", user, status, body)
+	}
+}
+
+// call sends a JSON-RPC request shaped like the Postman collection's and
+// returns the result message, or the error.
+func (p *testPeer) call(method, user, function string, args ...string) (string, map[string]interface{}) {
+	params := map[string]interface{}{
+		"type":          1,
+		"chaincodeID":   map[string]string{"name": p.name},
+		"ctorMsg":       map[string]interface{}{"function": function, "args": args},
+		"secureContext": user,
+	}
+	if method == "deploy" {
+		params["chaincodeID"] = map[string]string{"path": "https://github.com/ibm-blockchain/learn-chaincode/finished"}
+	}
+	request, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params, "id": 1})
+	_, body := p.post("/chaincode", string(request))
+	if body["error"] != nil {
+		return "", body["error"].(map[string]interface{})
+	}
+	return body["result"].(map[string]interface{})["message"].(string), nil
+}
+
+func TestEmulatorPostmanFlow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emulator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "state.json")
+
+	p := newTestPeer(t, statePath)
+	defer p.server.Close()
+	if _, rpcErr := p.call("deploy", "admin", "init", "hi there"); rpcErr == nil || !strings.Contains(rpcErr["data"].(string), "not logged in") {
+		t.Errorf("deploy before login: %v", rpcErr)
+	}
+	status, _ := p.post("/registrar", `{"enrollId":"admin","enrollSecret":"wrong"}`)
+	if status != http.StatusUnauthorized {
+		t.Errorf("login with wrong secret: %d", status)
+	}
+	p.login("admin", "adminpw")
+	p.login("acme", "acmepw")
+
+	name, rpcErr := p.call("deploy", "admin", "init", "hi there")
+	if rpcErr != nil || len(name) != 128 {
+		t.Fatalf("deploy: %q %v", name, rpcErr)
+	}
+	p.name = name
+	if _, rpcErr = p.call("invoke", "admin", "write", "hello_world", "go away"); rpcErr != nil {
+		t.Fatalf("write: %v", rpcErr)
+	}
+	if value, _ := p.call("query", "admin", "read", "hello_world"); value != "go away" {
+		t.Errorf("read = %q", value)
+	}
+	if _, rpcErr = p.call("invoke", "admin", "bindVirkNum", "acme", "12345678"); rpcErr != nil {
+		t.Fatalf("bind: %v", rpcErr)
+	}
+	if _, rpcErr = p.call("invoke", "acme", "addToLogBog", "0101901234", "12345678", "Bob", "2026-01-05", "7"); rpcErr != nil {
+		t.Fatalf("add: %v", rpcErr)
+	}
+
+	// a failed invoke reports the chaincode's error and leaves the state alone
+	before, _ := ioutil.ReadFile(statePath)
+	_, rpcErr = p.call("invoke", "acme", "addToLogBog", "0101901234", "87654321", "Bob", "2026-01-05", "7")
+	if rpcErr == nil || rpcErr["code"].(float64) != rpcInvocationError || !strings.Contains(rpcErr["data"].(string), errCodePermissionDenied) {
+		t.Errorf("add for another VirkNum: %v", rpcErr)
+	}
+	after, _ := ioutil.ReadFile(statePath)
+	if !bytes.Equal(before, after) {
+		t.Errorf("failed invoke changed the state file")
+	}
+	if _, rpcErr = p.call("mine", "admin", "read", "hello_world"); rpcErr == nil || rpcErr["code"].(float64) != rpcMethodNotFound {
+		t.Errorf("unknown method: %v", rpcErr)
+	}
+	p.name = "other"
+	if _, rpcErr = p.call("query", "admin", "read", "hello_world"); rpcErr == nil || rpcErr["code"].(float64) != rpcQueryError {
+		t.Errorf("query of another chaincode: %v", rpcErr)
+	}
+	p.server.Close()
+
+	// a restarted emulator picks the state up from the file
+	p = newTestPeer(t, statePath)
+	defer p.server.Close()
+	if p.emulator.stubs[name] == nil {
+		t.Errorf("restarted without %q", name)
+	}
+	p.name = name
+	p.login("acme", "acmepw")
+	found, rpcErr := p.call("query", "acme", "searchLogBog", "", "12345678")
+	if rpcErr != nil || !strings.Contains(found, `"DOW":"2026-01-05"`) {
+		t.Errorf("search after restart: %s %v", found, rpcErr)
+	}
+}
+
+func TestEmulatorKeepsEachChaincode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emulator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "state.json")
+
+	// a state file from before the emulator kept several chaincodes
+	err = ioutil.WriteFile(statePath, []byte(`{"chaincodeName":"old","state":{"hello_world":"aGk="}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newTestPeer(t, statePath)
+	defer p.server.Close()
+	p.login("admin", "adminpw")
+	p.name = "old"
+	if value, rpcErr := p.call("query", "admin", "read", "hello_world"); rpcErr != nil || !strings.Contains(value, "hi") {
+		t.Errorf("read from the old state file = %q %v", value, rpcErr)
+	}
+
+	first, rpcErr := p.call("deploy", "admin", "init", "first")
+	if rpcErr != nil {
+		t.Fatalf("deploy: %v", rpcErr)
+	}
+	second, rpcErr := p.call("deploy", "admin", "init", "second")
+	if rpcErr != nil || second == first {
+		t.Fatalf("deploy with other args: %q %v", second, rpcErr)
+	}
+	for name, hello := range map[string]string{first: "first", second: "second"} {
+		p.name = name
+		if value, rpcErr := p.call("query", "admin", "read", "hello_Block"); rpcErr != nil || !strings.Contains(value, hello) {
+			t.Errorf("hello_Block of %s = %q %v", hello, value, rpcErr)
+		}
+	}
+	p.server.Close()
+
+	p = newTestPeer(t, statePath)
+	defer p.server.Close()
+	if len(p.emulator.stubs) != 3 {
+		t.Errorf("restarted with %d chaincodes", len(p.emulator.stubs))
+	}
+}
@@ -0,0 +1,294 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Certificate attributes read by the access checks: the caller's role, the
+// enrolled user, and the CPRNum an employee may act for. The VirkNums an
+// employer may act for are bound to its identity by bindVirkNum instead.
+const (
+	roleAttribute     = "role"
+	identityAttribute = "enrollmentID"
+	cprNumAttribute   = "cprNum"
+)
+
+// Roles a caller's certificate may carry.
+const (
+	roleEmployer = "employer"
+	roleEmployee = "employee"
+	roleAuditor  = "auditor"
+	roleAdmin    = "admin"
+)
+
+// accessRule says which roles may call a function. Employers are limited to
+// their bound VirkNums in argument VirkArg and employees to the CPRNum in argument
+// CPRArg; -1 means the function takes no such argument. Auditors and
+// administrators are never scoped.
+type accessRule struct {
+	Roles   []string
+	VirkArg int
+	CPRArg  int
+}
+
+// logBogAccessPolicy holds the rule for every function in logBogArgSpecs.
+// A function without a rule cannot be called by anyone.
+var logBogAccessPolicy = map[string]accessRule{
+	"init":                    {Roles: []string{roleAdmin}, VirkArg: -1, CPRArg: -1},
+	"write":                   {Roles: []string{roleAdmin}, VirkArg: -1, CPRArg: -1},
+	"read":                    {Roles: []string{roleAuditor, roleAdmin}, VirkArg: -1, CPRArg: -1},
+	"readVersion":             {Roles: []string{roleAuditor, roleAdmin}, VirkArg: -1, CPRArg: -1},
+	"addToLogBog":             {Roles: []string{roleEmployer, roleAdmin}, VirkArg: 1, CPRArg: -1},
+	"upsertLogBog":            {Roles: []string{roleEmployer, roleAdmin}, VirkArg: 1, CPRArg: -1},
+	"bulkAddToLogBog":         {Roles: []string{roleEmployer, roleAdmin}, VirkArg: -1, CPRArg: -1}, // each row is checked as addToLogBog
+	"checkBulkAddToLogBog":    {Roles: []string{roleEmployer, roleAdmin}, VirkArg: -1, CPRArg: -1},
+	"updateLogBog":            {Roles: []string{roleEmployer, roleAdmin}, VirkArg: 1, CPRArg: -1},
+	"retractLogBog":           {Roles: []string{roleEmployer, roleAdmin}, VirkArg: 1, CPRArg: -1},
+	"searchLogBog":            {Roles: []string{roleEmployer, roleEmployee, roleAuditor, roleAdmin}, VirkArg: 1, CPRArg: 0},
+	"reportHours":             {Roles: []string{roleEmployer, roleEmployee, roleAuditor, roleAdmin}, VirkArg: 4, CPRArg: 3},
+	"exportLogBog":            {Roles: []string{roleEmployer, roleAuditor, roleAdmin}, VirkArg: 0, CPRArg: -1},
+	"historyLogBog":           {Roles: []string{roleEmployer, roleEmployee, roleAuditor, roleAdmin}, VirkArg: 1, CPRArg: 0},
+	"migrateLogBogRepository": {Roles: []string{roleAdmin}, VirkArg: -1, CPRArg: -1},
+	"migrateLogBog":           {Roles: []string{roleAdmin}, VirkArg: -1, CPRArg: -1},
+	"eraseSubject":            {Roles: []string{roleAdmin}, VirkArg: -1, CPRArg: -1},
+	"bindVirkNum":             {Roles: []string{roleAdmin}, VirkArg: -1, CPRArg: -1},
+	"unbindVirkNum":           {Roles: []string{roleAdmin}, VirkArg: -1, CPRArg: -1},
+	"getVirkBinding":          {Roles: []string{roleAuditor, roleAdmin}, VirkArg: -1, CPRArg: -1},
+}
+
+// callerIdentity names the submitter of the current transaction. Transaction
+// certificates differ per transaction, so the enrollmentID attribute is
+// preferred and the certificate fingerprint only used without it.
+func callerIdentity(stub shim.ChaincodeStubInterface) string {
+	enrollmentID, err := stub.ReadCertAttribute(identityAttribute)
+	if err == nil && len(enrollmentID) > 0 {
+		return string(enrollmentID)
+	}
+	cert, err := stub.GetCallerCertificate()
+	if err != nil || len(cert) == 0 {
+		return ""
+	}
+	fingerprint := sha256.Sum256(cert)
+	return "cert:" + hex.EncodeToString(fingerprint[:])
+}
+
+// callerRole returns the role attribute of the caller's certificate, or ""
+// when the caller has none.
+func callerRole(stub shim.ChaincodeStubInterface) string {
+	role, err := stub.ReadCertAttribute(roleAttribute)
+	if err != nil {
+		return ""
+	}
+	return string(role)
+}
+
+// requireAdmin fails with PERMISSION_DENIED unless the caller is an administrator.
+func requireAdmin(stub shim.ChaincodeStubInterface, function string) error {
+	if callerRole(stub) != roleAdmin {
+		return newPermissionDenied(function + " is restricted to administrators")
+	}
+	return nil
+}
+
+func newPermissionDenied(message string) error {
+	return &logBogError{Code: errCodePermissionDenied, Message: message}
+}
+
+// checkAccess applies the access rule for function to the caller and args.
+func checkAccess(stub shim.ChaincodeStubInterface, function string, args []string) error {
+	rule, ok := logBogAccessPolicy[function]
+	if !ok {
+		return newPermissionDenied(function + " has no access rule")
+	}
+	role := callerRole(stub)
+	allowed := false
+	for _, r := range rule.Roles {
+		if r == role {
+			allowed = true
+		}
+	}
+	if !allowed {
+		if len(role) == 0 {
+			return newPermissionDenied(function + " requires a role attribute in the caller's certificate")
+		}
+		return newPermissionDenied("Role " + role + " may not call " + function)
+	}
+
+	if role == roleEmployer && rule.VirkArg >= 0 {
+		binding, err := getVirkBinding(stub, callerIdentity(stub))
+		if err != nil {
+			return err
+		}
+		granted := make([]string, len(binding.VirkNums))
+		for i, virkNum := range binding.VirkNums {
+			granted[i] = strconv.Itoa(virkNum)
+		}
+		return checkScope(function, "VirkNum", granted, args, rule.VirkArg, parseVirkNum)
+	}
+	if role == roleEmployee && rule.CPRArg >= 0 {
+		cprNum, err := stub.ReadCertAttribute(cprNumAttribute)
+		if err != nil {
+			return newPermissionDenied("Caller's certificate has no " + cprNumAttribute + " attribute")
+		}
+		return checkScope(function, "CPRNum", strings.Split(string(cprNum), ","), args, rule.CPRArg, parseCPR)
+	}
+	return nil
+}
+
+// checkScope requires args[index] to equal one of the granted values, after
+// both are parsed with parse.
+func checkScope(function, field string, granted []string, args []string, index int, parse func(string) (int, error)) error {
+	if index >= len(args) || len(args[index]) == 0 {
+		return newPermissionDenied(function + " must name the " + field + " the caller acts for")
+	}
+	requested, err := parse(args[index])
+	if err != nil {
+		return newPermissionDenied(function + " must name a valid " + field)
+	}
+	for _, value := range granted {
+		allowed, err := parse(strings.TrimSpace(value))
+		if err == nil && allowed == requested {
+			return nil
+		}
+	}
+	return newPermissionDenied("Caller may not call " + function + " for " + field + " " + args[index])
+}
+
+// ============================================================================================================================
+// VirkNum bindings - the companies an employer identity may log hours for, kept under LogBog_binding_<identity>
+// ============================================================================================================================
+const logBogBindingPrefix = "LogBog_binding_"
+
+type virkBinding struct {
+	Identity string `json:"Identity"`
+	VirkNums []int  `json:"VirkNums"`
+}
+
+// getVirkBinding returns the VirkNums bound to identity, none if it has no binding.
+func getVirkBinding(stub shim.ChaincodeStubInterface, identity string) (virkBinding, error) {
+	binding := virkBinding{Identity: identity, VirkNums: []int{}}
+	if len(identity) == 0 {
+		return binding, nil
+	}
+	key := logBogBindingPrefix + identity
+	jsonAsBytes, err := stub.GetState(key)
+	if err != nil {
+		return binding, newStateError(key, err)
+	}
+	if jsonAsBytes == nil {
+		return binding, nil
+	}
+	err = json.Unmarshal(jsonAsBytes, &binding)
+	if err != nil {
+		return binding, newDecodeError(key, err)
+	}
+	return binding, nil
+}
+
+func putVirkBinding(stub shim.ChaincodeStubInterface, binding virkBinding) error {
+	key := logBogBindingPrefix + binding.Identity
+	if len(binding.VirkNums) == 0 {
+		err := stub.DelState(key)
+		if err != nil {
+			return newStateError(key, err)
+		}
+		return nil
+	}
+	jsonAsBytes, err := json.Marshal(binding)
+	if err != nil {
+		return err
+	}
+	err = stub.PutState(key, jsonAsBytes)
+	if err != nil {
+		return newStateError(key, err)
+	}
+	return nil
+}
+
+// bindVirkNum - admin invoke binding an employer identity to one more VirkNum
+func (t *SimpleChaincode) bindVirkNum(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//     0            1
+	// "identity", "VirkNum"
+	virkNum, _ := parseVirkNum(args[1])
+	binding, err := getVirkBinding(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, bound := range binding.VirkNums {
+		if bound == virkNum {
+			return json.Marshal(binding)
+		}
+	}
+	binding.VirkNums = append(binding.VirkNums, virkNum)
+	sort.Ints(binding.VirkNums)
+	fmt.Println("binding " + args[0] + " to VirkNum " + strconv.Itoa(virkNum))
+
+	err = putVirkBinding(stub, binding)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(binding)
+}
+
+// unbindVirkNum - admin invoke removing a VirkNum from an employer identity
+func (t *SimpleChaincode) unbindVirkNum(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//     0            1
+	// "identity", "VirkNum"
+	virkNum, _ := parseVirkNum(args[1])
+	binding, err := getVirkBinding(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	remaining := []int{}
+	for _, bound := range binding.VirkNums {
+		if bound != virkNum {
+			remaining = append(remaining, bound)
+		}
+	}
+	if len(remaining) == len(binding.VirkNums) {
+		return nil, newLogBogError(errCodeNotFound, logBogBindingPrefix+args[0], args[0]+" is not bound to VirkNum "+args[1])
+	}
+	binding.VirkNums = remaining
+	fmt.Println("unbinding " + args[0] + " from VirkNum " + strconv.Itoa(virkNum))
+
+	err = putVirkBinding(stub, binding)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(binding)
+}
+
+// queryVirkBinding - query the VirkNums bound to an identity
+func (t *SimpleChaincode) queryVirkBinding(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//     0
+	// "identity"
+	binding, err := getVirkBinding(stub, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(binding)
+}
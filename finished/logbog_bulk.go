@@ -0,0 +1,322 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// bulkAddToLogBog takes a batch of entries as CSV text with a header row, or
+// as a JSON array of objects, both naming the addToLogBog fields:
+//
+//	CPRNum;VirkNum;CPRNavn;DOW;NoOfHours;Comments;StartTime;EndTime
+//	0101901234;12345678;Bob Jensen;2026-01-05;7,5;;;
+//
+// Columns may come in any order and the last three may be left out. A CSV
+// header with more semicolons than commas is read as semicolon separated,
+// the way spreadsheets with a decimal comma save it.
+//
+// Every row is checked before anything is written, and if any row fails the
+// call fails with BATCH_REJECTED listing each row's error. Rows can also fail
+// on the working-time rules while they are written, against the rows before
+// them; the call then fails the same way, and the peer discards the writes of
+// the failed transaction, so a batch is stored whole or not at all.
+//
+// On a peer an invoke only answers with its transaction id, so a client learns
+// of neither failure that way. checkBulkAddToLogBog is the query to ask first:
+// it runs the same batch on a dryRunStub and answers as the invoke would.
+const maxBulkRows = 500
+
+// bulkColumns maps the lowercased column names bulkAddToLogBog accepts to the
+// position of the addToLogBog argument they fill.
+var bulkColumns = map[string]int{
+	"cprnum":     0,
+	"virknum":    1,
+	"cprnavn":    2,
+	"dow":        3,
+	"dateofwork": 3,
+	"noofhours":  4,
+	"comments":   5,
+	"comment":    5,
+	"starttime":  6,
+	"endtime":    7,
+}
+
+var bulkRequiredColumns = []string{"CPRNum", "VirkNum", "CPRNavn", "DOW"}
+
+const (
+	bulkAdd    = "add"
+	bulkUpsert = "upsert"
+)
+
+type bulkResult struct {
+	Rows     int `json:"Rows"`
+	Added    int `json:"Added"`
+	Replaced int `json:"Replaced"`
+}
+
+// bulkRow is one row of a batch as addToLogBog arguments.
+type bulkRow struct {
+	Row  int
+	Args []string
+}
+
+// ============================================================================================================================
+// Bulk Add - add, or with mode upsert add or replace, every entry of a CSV or JSON batch in one transaction
+// ============================================================================================================================
+func (t *SimpleChaincode) bulkAddSKATEmployees(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//      0                  1
+	// "entries", "mode (optional, add or upsert)"
+	replace := len(args) > 1 && args[1] == bulkUpsert
+	rows, err := parseBulkEntries(args[0])
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("bulk adding " + strconv.Itoa(len(rows)) + " entries")
+
+	// check every row before writing any
+	function := "addToLogBog"
+	if replace {
+		function = "upsertLogBog"
+	}
+	employees := make([]SKATEmployee, len(rows))
+	rowErrors := []logBogRowError{}
+	firstRow := map[string]int{}
+	for i, row := range rows {
+		err = validateArgs(row.Args, logBogArgSpecs[function])
+		if err == nil {
+			err = checkAccess(stub, function, row.Args)
+		}
+		if err == nil {
+			employees[i], err = parseSKATEmployee(stub, row.Args)
+		}
+		if err == nil {
+			err = checkBulkEntry(stub, employees[i], replace)
+		}
+		if err == nil {
+			key := logBogEntryKey(employees[i])
+			if first, ok := firstRow[key]; ok {
+				err = newLogBogError(errCodeAlreadyExists, "", "Row repeats the entry of row "+strconv.Itoa(first))
+			}
+			firstRow[key] = row.Row
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, newRowError(row.Row, err))
+		}
+	}
+	if len(rowErrors) > 0 {
+		return nil, newBatchRejected(len(rows), rowErrors)
+	}
+
+	result := bulkResult{Rows: len(rows)}
+	changes := []logBogChange{}
+	for i, employee := range employees {
+		_, change, err := t.putSKATEmployee(stub, employee, replace)
+		if err != nil {
+			rowErrors = append(rowErrors, newRowError(rows[i].Row, err))
+			continue
+		}
+		if change.Operation == opUpsert {
+			result.Replaced++
+		} else {
+			result.Added++
+		}
+		changes = append(changes, change)
+	}
+	if len(rowErrors) > 0 {
+		return nil, newBatchRejected(len(rows), rowErrors)
+	}
+	err = emitLogBogEvent(stub, changes...)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("bulk added " + strconv.Itoa(result.Added) + " and replaced " + strconv.Itoa(result.Replaced) + " entries")
+	return json.Marshal(result)
+}
+
+// ============================================================================================================================
+// Check Bulk Add - run bulkAddToLogBog on a batch without storing it and return its result or BATCH_REJECTED error
+// ============================================================================================================================
+func (t *SimpleChaincode) checkBulkAddSKATEmployees(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	return t.bulkAddSKATEmployees(newDryRunStub(stub), args)
+}
+
+// checkBulkEntry fails, without writing, where putSKATEmployee would before
+// the working-time rules: on a missing key or, unless replacing, an existing entry.
+func checkBulkEntry(stub shim.ChaincodeStubInterface, employee SKATEmployee, replace bool) error {
+	sealed, err := sealLogBogEntry(stub, employee)
+	if err != nil || replace {
+		return err
+	}
+	key := logBogEntryKey(sealed)
+	existingJsonAsBytes, err := stub.GetState(key)
+	if err != nil {
+		return newStateError(key, err)
+	}
+	if existingJsonAsBytes != nil {
+		return newLogBogError(errCodeAlreadyExists, key, "Employee log already exists, use mode upsert to replace it")
+	}
+	return nil
+}
+
+func newRowError(row int, err error) logBogRowError {
+	logBogErr, ok := err.(*logBogError)
+	if !ok {
+		return logBogRowError{Row: row, Code: errCodeStateFailure, Message: err.Error()}
+	}
+	return logBogRowError{Row: row, Code: logBogErr.Code, Field: logBogErr.Field, Message: logBogErr.Message}
+}
+
+func newBatchRejected(rows int, rowErrors []logBogRowError) error {
+	return &logBogError{
+		Code:    errCodeBatchRejected,
+		Message: strconv.Itoa(len(rowErrors)) + " of " + strconv.Itoa(rows) + " rows rejected, nothing was stored",
+		Rows:    rowErrors,
+	}
+}
+
+// parseBulkEntries reads a CSV or JSON batch into addToLogBog arguments.
+func parseBulkEntries(entries string) ([]bulkRow, error) {
+	var rows []bulkRow
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(entries), "[") {
+		rows, err = parseBulkJSON(entries)
+	} else {
+		rows, err = parseBulkCSV(entries)
+	}
+	if err != nil {
+		return nil, newFieldError("entries", "entries "+err.Error())
+	}
+	if len(rows) == 0 {
+		return nil, newFieldError("entries", "entries hold no rows")
+	}
+	if len(rows) > maxBulkRows {
+		return nil, newFieldError("entries", "entries hold "+strconv.Itoa(len(rows))+" rows, at most "+strconv.Itoa(maxBulkRows)+" are taken at a time")
+	}
+	return rows, nil
+}
+
+func parseBulkCSV(entries string) ([]bulkRow, error) {
+	reader := csv.NewReader(strings.NewReader(entries))
+	header := strings.SplitN(entries, "\n", 2)[0]
+	if strings.Count(header, ";") > strings.Count(header, ",") {
+		reader.Comma = ';'
+	}
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.New("are not valid CSV: " + err.Error())
+	}
+	if len(records) == 0 {
+		return nil, errors.New("have no header row")
+	}
+
+	positions, err := bulkColumnPositions(records[0])
+	if err != nil {
+		return nil, err
+	}
+	rows := []bulkRow{}
+	for i, record := range records[1:] {
+		args := make([]string, len(storeLogBogArgs))
+		for column, value := range record {
+			args[positions[column]] = strings.TrimSpace(value)
+		}
+		rows = append(rows, bulkRow{Row: i + 2, Args: args})
+	}
+	return rows, nil
+}
+
+func parseBulkJSON(entries string) ([]bulkRow, error) {
+	var objects []map[string]json.RawMessage
+	err := json.Unmarshal([]byte(entries), &objects)
+	if err != nil {
+		return nil, errors.New("are not a JSON array of objects: " + err.Error())
+	}
+	rows := []bulkRow{}
+	for i, object := range objects {
+		names := []string{}
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		positions, err := bulkColumnPositions(names)
+		if err != nil {
+			return nil, errors.New("row " + strconv.Itoa(i+1) + ": " + err.Error())
+		}
+		args := make([]string, len(storeLogBogArgs))
+		for column, name := range names {
+			args[positions[column]] = bulkJSONValue(object[name])
+		}
+		// a CPRNum given as a number has lost its leading zero
+		if len(args[0]) == 9 && strings.Trim(args[0], "0123456789") == "" {
+			args[0] = "0" + args[0]
+		}
+		rows = append(rows, bulkRow{Row: i + 1, Args: args})
+	}
+	return rows, nil
+}
+
+// bulkJSONValue returns a JSON string unquoted and any other value, such as
+// a number, as written; null is empty.
+func bulkJSONValue(raw json.RawMessage) string {
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return strings.TrimSpace(text)
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
+
+// bulkColumnPositions maps each column name to the addToLogBog argument it fills.
+func bulkColumnPositions(names []string) ([]int, error) {
+	positions := make([]int, len(names))
+	seen := map[int]bool{}
+	for i, name := range names {
+		position, ok := bulkColumns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, errors.New("have an unknown column " + name)
+		}
+		if seen[position] {
+			return nil, errors.New("name the " + storeLogBogArgs[position].Field + " column twice")
+		}
+		seen[position] = true
+		positions[i] = position
+	}
+	for position, name := range bulkRequiredColumns {
+		if !seen[position] {
+			return nil, errors.New("have no " + name + " column")
+		}
+	}
+	return positions, nil
+}
+
+func checkBulkMode(value string) error {
+	if value != bulkAdd && value != bulkUpsert {
+		return errors.New("must be " + bulkAdd + " or " + bulkUpsert + ": " + value)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+const logBogConfigKey = "LogBogConfig"
+
+// logBogConfig holds the per-deployment settings passed as the optional
+// second Init argument, e.g. {"dateGraceDays": 1}.
+type logBogConfig struct {
+	// DateGraceDays is how many days after the transaction date a DateOfWork may lie
+	DateGraceDays int `json:"dateGraceDays"`
+	// Pseudonymise keeps CPR numbers and names out of state in clear, see
+	// logbog_privacy.go. The secret travels in the transaction metadata, which
+	// v0.6 stores in the blocks. It cannot be switched once entries are stored.
+	Pseudonymise bool `json:"pseudonymise"`
+	// KeyCheck identifies the logBogKey a pseudonymising deployment accepts.
+	// It is set by Init or the first entry written, never from the config argument.
+	KeyCheck string `json:"keyCheck,omitempty"`
+
+	// WorkingTimeRules is off, reject or flag, see logbog_rules.go
+	WorkingTimeRules string `json:"workingTimeRules"`
+	// MaxDailyHours caps one person's hours per day across all VirkNums
+	MaxDailyHours int `json:"maxDailyHours"`
+	// MinRestHours of daily rest further caps a day at 24 - MinRestHours
+	MinRestHours int `json:"minRestHours"`
+	// MaxWeeklyAverageHours caps the weekly average over ReferencePeriodWeeks
+	MaxWeeklyAverageHours int `json:"maxWeeklyAverageHours"`
+	ReferencePeriodWeeks  int `json:"referencePeriodWeeks"`
+}
+
+func defaultLogBogConfig() logBogConfig {
+	return logBogConfig{
+		DateGraceDays:         1,
+		WorkingTimeRules:      rulesOff,
+		MaxDailyHours:         13,
+		MinRestHours:          11,
+		MaxWeeklyAverageHours: 48,
+		ReferencePeriodWeeks:  17,
+	}
+}
+
+// parseLogBogConfig decodes a config argument on top of the defaults so
+// fields left out keep their default value.
+func parseLogBogConfig(value string) (logBogConfig, error) {
+	config := defaultLogBogConfig()
+	err := json.Unmarshal([]byte(value), &config)
+	if err != nil {
+		return config, errors.New("must be a JSON object: " + err.Error())
+	}
+	if config.DateGraceDays < 0 {
+		return config, errors.New("must not have a negative dateGraceDays")
+	}
+	if config.WorkingTimeRules != rulesOff && config.WorkingTimeRules != rulesReject && config.WorkingTimeRules != rulesFlag {
+		return config, errors.New("must have workingTimeRules " + rulesOff + ", " + rulesReject + " or " + rulesFlag)
+	}
+	if config.MaxDailyHours < 1 || config.MaxDailyHours > 24 {
+		return config, errors.New("must have maxDailyHours from 1 to 24")
+	}
+	if config.MinRestHours < 0 || config.MinRestHours > 23 {
+		return config, errors.New("must have minRestHours from 0 to 23")
+	}
+	if config.MaxWeeklyAverageHours < 1 || config.MaxWeeklyAverageHours > 168 {
+		return config, errors.New("must have maxWeeklyAverageHours from 1 to 168")
+	}
+	if config.ReferencePeriodWeeks < 1 || config.ReferencePeriodWeeks > 52 {
+		return config, errors.New("must have referencePeriodWeeks from 1 to 52")
+	}
+	return config, nil
+}
+
+func putLogBogConfig(stub shim.ChaincodeStubInterface, config logBogConfig) error {
+	jsonAsBytes, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(logBogConfigKey, jsonAsBytes)
+}
+
+// getLogBogConfig returns the stored config, or the defaults if Init never stored one.
+func getLogBogConfig(stub shim.ChaincodeStubInterface) (logBogConfig, error) {
+	config := defaultLogBogConfig()
+	jsonAsBytes, err := stub.GetState(logBogConfigKey)
+	if err != nil {
+		return config, newStateError(logBogConfigKey, err)
+	}
+	if jsonAsBytes == nil {
+		return config, nil
+	}
+	err = json.Unmarshal(jsonAsBytes, &config)
+	if err != nil {
+		return config, newDecodeError(logBogConfigKey, err)
+	}
+	return config, nil
+}
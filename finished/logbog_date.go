@@ -0,0 +1,159 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// dateOfWorkFormat is the canonical ISO-8601 form DateOfWork is stored and keyed in.
+const dateOfWorkFormat = "2006-01-02"
+
+// dateOfWorkLayouts are the spellings of a day accepted for DateOfWork, tried
+// in order. Day-first layouts follow Danish convention.
+var dateOfWorkLayouts = []string{
+	dateOfWorkFormat,
+	time.RFC3339,
+	"02-01-2006",
+	"2-1-2006",
+	"02/01/2006",
+	"2/1/2006",
+	"02.01.2006",
+	"2.1.2006",
+	"20060102",
+	"2 Jan 2006",
+	"2 January 2006",
+	"Jan 2 2006",
+	"January 2 2006",
+}
+
+// parseDateOfWork parses a DateOfWork string into midnight UTC of that day.
+func parseDateOfWork(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateOfWorkLayouts {
+		date, err := time.Parse(layout, value)
+		if err == nil {
+			return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, errors.New("must be a date, preferably YYYY-MM-DD: " + value)
+}
+
+// normaliseDateOfWork returns the canonical form of a DateOfWork string.
+func normaliseDateOfWork(value string) (string, error) {
+	date, err := parseDateOfWork(value)
+	if err != nil {
+		return "", err
+	}
+	return date.Format(dateOfWorkFormat), nil
+}
+
+// getTxTime returns the transaction timestamp in UTC, and false when the
+// stub does not provide one.
+func getTxTime(stub shim.ChaincodeStubInterface) (time.Time, bool) {
+	txTimestamp, err := stub.GetTxTimestamp()
+	if err != nil || txTimestamp == nil {
+		return time.Time{}, false
+	}
+	return time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)).UTC(), true
+}
+
+// txTimeString formats the transaction timestamp as RFC 3339, or "" without one.
+func txTimeString(stub shim.ChaincodeStubInterface) string {
+	txTime, ok := getTxTime(stub)
+	if !ok {
+		return ""
+	}
+	return txTime.Format(time.RFC3339)
+}
+
+// checkDateOfWork rejects dates more than the configured grace period after
+// the transaction date. The transaction timestamp rather than the peer clock
+// is used so every peer reaches the same result; without one no date can be
+// stored, as a peer clock fallback would let peers disagree.
+func checkDateOfWork(stub shim.ChaincodeStubInterface, date time.Time) error {
+	txTime, ok := getTxTime(stub)
+	if !ok {
+		return &logBogError{Code: errCodeStateFailure, Field: "DateOfWork", Message: "DateOfWork cannot be checked without a transaction timestamp"}
+	}
+	config, err := getLogBogConfig(stub)
+	if err != nil {
+		return err
+	}
+	today := time.Date(txTime.Year(), txTime.Month(), txTime.Day(), 0, 0, 0, 0, time.UTC)
+	if date.After(today.AddDate(0, 0, config.DateGraceDays)) {
+		return newFieldError("DateOfWork", "DateOfWork "+date.Format(dateOfWorkFormat)+" is more than "+strconv.Itoa(config.DateGraceDays)+" day(s) in the future")
+	}
+	return nil
+}
+
+// dateWindow is an inclusive range of days; a zero bound leaves that side open.
+type dateWindow struct {
+	From time.Time
+	To   time.Time
+}
+
+// newDateWindow parses optional from/to arguments, either of which may be empty.
+func newDateWindow(from, to string) (dateWindow, error) {
+	var window dateWindow
+	var err error
+
+	if len(from) > 0 {
+		window.From, err = parseDateOfWork(from)
+		if err != nil {
+			return window, newFieldError("from", "from "+err.Error())
+		}
+	}
+	if len(to) > 0 {
+		window.To, err = parseDateOfWork(to)
+		if err != nil {
+			return window, newFieldError("to", "to "+err.Error())
+		}
+	}
+	if !window.From.IsZero() && !window.To.IsZero() && window.To.Before(window.From) {
+		return window, newFieldError("to", "to date "+to+" is before from date "+from)
+	}
+	return window, nil
+}
+
+func (w dateWindow) isOpen() bool {
+	return w.From.IsZero() && w.To.IsZero()
+}
+
+// contains reports whether dateOfWork falls inside the window. Dates that
+// cannot be parsed only match an open window.
+func (w dateWindow) contains(dateOfWork string) bool {
+	if w.isOpen() {
+		return true
+	}
+	date, err := parseDateOfWork(dateOfWork)
+	if err != nil {
+		return false
+	}
+	if !w.From.IsZero() && date.Before(w.From) {
+		return false
+	}
+	if !w.To.IsZero() && date.After(w.To) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// dryRunStub lets a query run an invoke function to see what it would do. The
+// function reads the world state with its own writes applied, as it would in
+// a transaction, but the writes are only kept in memory and no event is set.
+type dryRunStub struct {
+	shim.ChaincodeStubInterface
+	// writes holds the values written, nil for a deleted key
+	writes map[string][]byte
+}
+
+func newDryRunStub(stub shim.ChaincodeStubInterface) *dryRunStub {
+	return &dryRunStub{ChaincodeStubInterface: stub, writes: map[string][]byte{}}
+}
+
+func (s *dryRunStub) GetState(key string) ([]byte, error) {
+	if value, ok := s.writes[key]; ok {
+		return value, nil
+	}
+	return s.ChaincodeStubInterface.GetState(key)
+}
+
+func (s *dryRunStub) PutState(key string, value []byte) error {
+	s.writes[key] = append([]byte{}, value...)
+	return nil
+}
+
+func (s *dryRunStub) DelState(key string) error {
+	s.writes[key] = nil
+	return nil
+}
+
+func (s *dryRunStub) SetEvent(name string, payload []byte) error {
+	return nil
+}
+
+// RangeQueryState merges the writes inside the range into the stored keys.
+func (s *dryRunStub) RangeQueryState(startKey, endKey string) (shim.StateRangeQueryIteratorInterface, error) {
+	merged := map[string][]byte{}
+
+	iter, err := s.ChaincodeStubInterface.RangeQueryState(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	for iter.HasNext() {
+		key, value, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		merged[key] = value
+	}
+	for key, value := range s.writes {
+		if key < startKey || key >= endKey {
+			continue
+		}
+		if value == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = value
+		}
+	}
+
+	result := &dryRunIterator{}
+	for key := range merged {
+		result.keys = append(result.keys, key)
+	}
+	sort.Strings(result.keys)
+	for _, key := range result.keys {
+		result.values = append(result.values, merged[key])
+	}
+	return result, nil
+}
+
+// dryRunIterator walks the keys and values of a dryRunStub range in key order.
+type dryRunIterator struct {
+	keys   []string
+	values [][]byte
+	next   int
+}
+
+func (iter *dryRunIterator) HasNext() bool {
+	return iter.next < len(iter.keys)
+}
+
+func (iter *dryRunIterator) Next() (string, []byte, error) {
+	iter.next++
+	return iter.keys[iter.next-1], iter.values[iter.next-1], nil
+}
+
+func (iter *dryRunIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,221 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// eraseSubject anonymises one CPRNum in world state: every entry is replaced
+// by an anonymous copy that keeps only VirkNum, DateOfWork, NoOfHours and the
+// retraction flag, keyed by a subject id derived from the transaction rather
+// than the CPR number, so hours per VirkNum per day still add up. The
+// subject's history chains are deleted, since every version carries the
+// personal fields, and each anonymous copy starts a chain of its own. A
+// receipt without any personal data is kept under LogBog_erasure_<TxID>.
+//
+// This is not erasure from the ledger. The blocks keep every earlier
+// transaction with its arguments, and the entries a pseudonymised deployment
+// wrote stay readable there with the one logBogKey the whole deployment
+// shares. The receipt says so in its Scope.
+const logBogErasurePrefix = "LogBog_erasure_"
+
+// anonymisationScope is what an anonymisation receipt covers.
+const anonymisationScope = "world state only; blocks before TxID still hold the personal data"
+
+type anonymisationReceipt struct {
+	Reference       string `json:"Reference"`
+	Subject         string `json:"Subject"`
+	Scope           string `json:"Scope"`
+	TxID            string `json:"TxID"`
+	Timestamp       string `json:"Timestamp"`
+	Submitter       string `json:"Submitter"`
+	Entries         int    `json:"Entries"`
+	LegacyEntries   int    `json:"LegacyEntries"`
+	HistoryVersions int    `json:"HistoryVersions"`
+	VirkNums        []int  `json:"VirkNums"`
+}
+
+// ============================================================================================================================
+// Erase Subject - anonymise every entry of a CPRNum in world state, drop its history and return the receipt
+// ============================================================================================================================
+func (t *SimpleChaincode) eraseSubject(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//     0             1
+	// "CPRNum", "reference of the erasure request"
+	cprNum, _ := parseCPR(args[0])
+	cprKey, err := logBogCPRKey(stub, strconv.Itoa(cprNum))
+	if err != nil {
+		return nil, err
+	}
+	subject := sha256.Sum256([]byte(stub.GetTxID()))
+	receipt := anonymisationReceipt{
+		Reference: args[1],
+		Subject:   "erased_" + hex.EncodeToString(subject[:8]),
+		Scope:     anonymisationScope,
+		TxID:      stub.GetTxID(),
+		Timestamp: txTimeString(stub),
+		Submitter: callerIdentity(stub),
+		VirkNums:  []int{},
+	}
+	fmt.Println("anonymising subject as " + receipt.Subject)
+
+	employees, err := takeLegacySubjectEntries(stub, cprNum)
+	if err != nil {
+		return nil, err
+	}
+	receipt.LegacyEntries = len(employees)
+	stored, err := scanLogBogEntries(stub, logBogEntryPrefix+cprKey+"_")
+	if err != nil {
+		return nil, err
+	}
+	employees = append(employees, stored...)
+
+	historyKeys, _, err := rangeLogBog(stub, logBogHistoryPrefix+cprKey+"_")
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range historyKeys {
+		err = stub.DelState(key)
+		if err != nil {
+			return nil, newStateError(key, err)
+		}
+	}
+	receipt.HistoryVersions = len(historyKeys)
+
+	if len(employees) == 0 && len(historyKeys) == 0 {
+		return nil, newLogBogError(errCodeNotFound, logBogEntryPrefix+cprKey, "No employee log found for "+args[0])
+	}
+
+	erased := map[string]bool{}
+	virkNums := map[int]bool{}
+	changes := []logBogChange{}
+	for _, employee := range employees {
+		err = delLogBogEntry(stub, employee)
+		if err != nil {
+			return nil, err
+		}
+		anonymous := SKATEmployee{
+			CPRHash:     receipt.Subject,
+			VirkNum:     employee.VirkNum,
+			DateOfWork:  employee.DateOfWork,
+			NoOfHours:   employee.NoOfHours,
+			Retracted:   employee.Retracted,
+			RetractedAt: employee.RetractedAt,
+			Erased:      true,
+			Version:     employee.Version + 1,
+		}
+		// a legacy copy of a migrated entry only counts once
+		if erased[logBogEntryKey(anonymous)] {
+			continue
+		}
+		erased[logBogEntryKey(anonymous)] = true
+		err = putLogBogEntry(stub, anonymous)
+		if err != nil {
+			return nil, err
+		}
+		err = appendLogBogVersion(stub, opErase, anonymous)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, newLogBogChange(stub, opErase, employee, anonymous))
+		if !virkNums[employee.VirkNum] {
+			virkNums[employee.VirkNum] = true
+			receipt.VirkNums = append(receipt.VirkNums, employee.VirkNum)
+		}
+	}
+	sort.Ints(receipt.VirkNums)
+	receipt.Entries = len(erased)
+	err = emitLogBogEvent(stub, changes...)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonAsBytes, err := json.Marshal(receipt)
+	if err != nil {
+		return nil, err
+	}
+	key := logBogErasurePrefix + receipt.TxID
+	err = stub.PutState(key, jsonAsBytes)
+	if err != nil {
+		return nil, newStateError(key, err)
+	}
+	fmt.Println("anonymised " + strconv.Itoa(receipt.Entries) + " entries and " + strconv.Itoa(receipt.HistoryVersions) + " versions")
+	return jsonAsBytes, nil
+}
+
+// takeLegacySubjectEntries removes the entries of cprNum from the legacy
+// SKATEmployeeRepository blob, and their CPRNum_VirkNum_DateOfWork keys, and
+// returns them.
+func takeLegacySubjectEntries(stub shim.ChaincodeStubInterface, cprNum int) ([]SKATEmployee, error) {
+	taken := []SKATEmployee{}
+
+	repositoryJsonAsBytes, err := stub.GetState(logBogRepositoryKey)
+	if err != nil {
+		return nil, newStateError(logBogRepositoryKey, err)
+	}
+	if repositoryJsonAsBytes == nil {
+		return taken, nil
+	}
+	var employeeRepository SKATEmployeeRepository
+	err = json.Unmarshal(repositoryJsonAsBytes, &employeeRepository)
+	if err != nil {
+		return nil, newDecodeError(logBogRepositoryKey, err)
+	}
+
+	remaining := []SKATEmployee{}
+	for _, skatEmployee := range employeeRepository.EmployeeList {
+		if skatEmployee.CPRNum != cprNum {
+			remaining = append(remaining, skatEmployee)
+			continue
+		}
+		legacyKey := strconv.Itoa(skatEmployee.CPRNum) + "_" + strconv.Itoa(skatEmployee.VirkNum) + "_" + skatEmployee.DateOfWork
+		err = stub.DelState(legacyKey)
+		if err != nil {
+			return nil, newStateError(legacyKey, err)
+		}
+		err = upgradeLogBogEntry(&skatEmployee)
+		if err != nil {
+			return nil, newDecodeError(logBogRepositoryKey, err)
+		}
+		dateOfWork, err := normaliseDateOfWork(skatEmployee.DateOfWork)
+		if err == nil {
+			skatEmployee.DateOfWork = dateOfWork
+		}
+		taken = append(taken, skatEmployee)
+	}
+	if len(taken) == 0 {
+		return taken, nil
+	}
+
+	employeeRepository.EmployeeList = remaining
+	repositoryJsonAsBytes, err = json.Marshal(employeeRepository)
+	if err != nil {
+		return nil, err
+	}
+	err = stub.PutState(logBogRepositoryKey, repositoryJsonAsBytes)
+	if err != nil {
+		return nil, newStateError(logBogRepositoryKey, err)
+	}
+	return taken, nil
+}
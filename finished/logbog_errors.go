@@ -0,0 +1,94 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+)
+
+// Stable codes carried by logBogError so clients need not parse messages.
+const (
+	errCodeInvalidArgumentCount = "INVALID_ARGUMENT_COUNT"
+	errCodeInvalidArgument      = "INVALID_ARGUMENT"
+	errCodeAlreadyExists        = "ALREADY_EXISTS"
+	errCodeNotFound             = "NOT_FOUND"
+	errCodeRetracted            = "RETRACTED"
+	errCodePermissionDenied     = "PERMISSION_DENIED"
+	errCodeKeyRequired          = "KEY_REQUIRED"
+	errCodeKeyMismatch          = "KEY_MISMATCH"
+	errCodeRuleViolation        = "RULE_VIOLATION"
+	errCodeBatchRejected        = "BATCH_REJECTED"
+	errCodeVersionConflict      = "VERSION_CONFLICT"
+	errCodeUnknownFunction      = "UNKNOWN_FUNCTION"
+	errCodeStateFailure         = "STATE_FAILURE"
+	errCodeCorruptState         = "CORRUPT_STATE"
+)
+
+// logBogError is returned by every Invoke and Query function. Its Error()
+// text is the JSON object itself, keeping the {"Error": ...} shape clients
+// already look for while adding a stable Code and, where one argument is at
+// fault, its Field name.
+type logBogError struct {
+	Message string `json:"Error"`
+	Code    string `json:"Code"`
+	Field   string `json:"Field,omitempty"`
+	Key     string `json:"Key,omitempty"`
+	// Rows lists what is wrong with each rejected row of a bulk call
+	Rows []logBogRowError `json:"Rows,omitempty"`
+}
+
+// logBogRowError is the error of one row of a bulk call, numbered as in the
+// CSV text counting the header as row 1, or from 1 in a JSON array.
+type logBogRowError struct {
+	Row     int    `json:"Row"`
+	Message string `json:"Error"`
+	Code    string `json:"Code"`
+	Field   string `json:"Field,omitempty"`
+}
+
+func (e *logBogError) Error() string {
+	jsonAsBytes, _ := json.Marshal(e)
+	return string(jsonAsBytes)
+}
+
+func newLogBogError(code, key, message string) error {
+	return &logBogError{Code: code, Key: key, Message: message}
+}
+
+// isLogBogError reports whether err is a logBogError carrying code.
+func isLogBogError(err error, code string) bool {
+	logBogErr, ok := err.(*logBogError)
+	return ok && logBogErr.Code == code
+}
+
+// newFieldError reports an argument that failed validation.
+func newFieldError(field, message string) error {
+	return &logBogError{Code: errCodeInvalidArgument, Field: field, Message: message}
+}
+
+// newStateError reports a failed GetState, PutState or DelState on key.
+func newStateError(key string, err error) error {
+	if _, ok := err.(*logBogError); ok {
+		return err
+	}
+	return &logBogError{Code: errCodeStateFailure, Key: key, Message: "Failed to access state for " + key + ": " + err.Error()}
+}
+
+// newDecodeError reports a stored value that is not the JSON expected under key.
+func newDecodeError(key string, err error) error {
+	return &logBogError{Code: errCodeCorruptState, Key: key, Message: "Failed to decode " + key + ": " + err.Error()}
+}
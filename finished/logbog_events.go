@@ -0,0 +1,125 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Every transaction that changes entries emits one logBogChangedEvent,
+// listing each change, since a transaction can only set a single event.
+// Listeners get the entry key with the CPR number masked, and must use
+// searchLogBog by VirkNum and DateOfWork to read the entry itself.
+const logBogChangedEvent = "LogBogChanged"
+
+type logBogChange struct {
+	Operation     string   `json:"Operation"`
+	Key           string   `json:"Key"`
+	VirkNum       int      `json:"VirkNum"`
+	DateOfWork    string   `json:"DOW"`
+	Version       int      `json:"Version"`
+	ChangedFields []string `json:"ChangedFields"`
+}
+
+type logBogEvent struct {
+	TxID    string         `json:"TxID"`
+	Changes []logBogChange `json:"Changes"`
+}
+
+// maskedEntryKey is the entry key with a clear CPR number cut to its birth
+// date, DDMMYY****. Pseudonymised and erased entries are already keyed without it.
+func maskedEntryKey(employee SKATEmployee) string {
+	if len(employee.CPRHash) > 0 {
+		return logBogEntryKey(employee)
+	}
+	return logBogEntryPrefix + maskCPR(employee.CPRNum) + "_" + strconv.Itoa(employee.VirkNum) + "_" + employee.DateOfWork
+}
+
+// changedLogBogFields names the fields that differ between two versions of an
+// entry; a zero SKATEmployee stands for an entry that did not or no longer exists.
+func changedLogBogFields(before, after SKATEmployee) []string {
+	changed := []string{}
+	if before.CPRNum != after.CPRNum || before.CPRHash != after.CPRHash {
+		changed = append(changed, "CPRNum")
+	}
+	if before.VirkNum != after.VirkNum {
+		changed = append(changed, "VirkNum")
+	}
+	if before.CPRNavn != after.CPRNavn || before.CPRNavnEnc != after.CPRNavnEnc {
+		changed = append(changed, "CPRNavn")
+	}
+	if before.DateOfWork != after.DateOfWork {
+		changed = append(changed, "DateOfWork")
+	}
+	if before.NoOfHours != after.NoOfHours {
+		changed = append(changed, "NoOfHours")
+	}
+	if before.Comment != after.Comment {
+		changed = append(changed, "Comment")
+	}
+	if before.StartTime != after.StartTime {
+		changed = append(changed, "StartTime")
+	}
+	if before.EndTime != after.EndTime {
+		changed = append(changed, "EndTime")
+	}
+	if before.Retracted != after.Retracted || before.RetractReason != after.RetractReason {
+		changed = append(changed, "Retracted")
+	}
+	if before.Erased != after.Erased {
+		changed = append(changed, "Erased")
+	}
+	return changed
+}
+
+// newLogBogChange describes operation turning before into after. Both are
+// opened first so re-encrypting an unchanged name does not count as a change.
+func newLogBogChange(stub shim.ChaincodeStubInterface, operation string, before, after SKATEmployee) logBogChange {
+	before = openLogBogEntry(stub, before)
+	after = openLogBogEntry(stub, after)
+	entry := after
+	if operation == opDelete {
+		entry = before
+	}
+	return logBogChange{
+		Operation:     operation,
+		Key:           maskedEntryKey(entry),
+		VirkNum:       entry.VirkNum,
+		DateOfWork:    entry.DateOfWork,
+		Version:       after.Version,
+		ChangedFields: changedLogBogFields(before, after),
+	}
+}
+
+// emitLogBogEvent sets the transaction's LogBogChanged event.
+func emitLogBogEvent(stub shim.ChaincodeStubInterface, changes ...logBogChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	jsonAsBytes, err := json.Marshal(logBogEvent{TxID: stub.GetTxID(), Changes: changes})
+	if err != nil {
+		return err
+	}
+	err = stub.SetEvent(logBogChangedEvent, jsonAsBytes)
+	if err != nil {
+		return &logBogError{Code: errCodeStateFailure, Message: "Failed to set event " + logBogChangedEvent + ": " + err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Formats exportLogBog writes.
+const (
+	exportCSV   = "csv"
+	exportJSONL = "jsonl"
+)
+
+// exportColumns is the order of the columns of a CSV export and of the keys
+// of each JSON Lines record. Add columns at the end only.
+var exportColumns = []string{
+	"VirkNum", "DOW", "CPRNum", "CPRHash", "CPRNavn", "NoOfHours", "StartTime", "EndTime", "Comments",
+	"Retracted", "RetractReason", "RetractedAt", "RuleViolations", "Version",
+}
+
+// exportRow is one exported entry, its fields in exportColumns order.
+type exportRow struct {
+	VirkNum        int      `json:"VirkNum"`
+	DateOfWork     string   `json:"DOW"`
+	CPRNum         string   `json:"CPRNum"`
+	CPRHash        string   `json:"CPRHash"`
+	CPRNavn        string   `json:"CPRNavn"`
+	NoOfHours      float64  `json:"NoOfHours"`
+	StartTime      string   `json:"StartTime"`
+	EndTime        string   `json:"EndTime"`
+	Comment        string   `json:"Comments"`
+	Retracted      bool     `json:"Retracted"`
+	RetractReason  string   `json:"RetractReason"`
+	RetractedAt    string   `json:"RetractedAt"`
+	RuleViolations []string `json:"RuleViolations"`
+	Version        int      `json:"Version"`
+}
+
+func (r exportRow) values() []string {
+	return []string{
+		strconv.Itoa(r.VirkNum), r.DateOfWork, r.CPRNum, r.CPRHash, r.CPRNavn,
+		strconv.FormatFloat(r.NoOfHours, 'f', -1, 64), r.StartTime, r.EndTime, r.Comment,
+		strconv.FormatBool(r.Retracted), r.RetractReason, r.RetractedAt, strings.Join(r.RuleViolations, "; "),
+		strconv.Itoa(r.Version),
+	}
+}
+
+// exportManifest describes an export so an offline copy can be checked:
+// SHA256 is the hex digest of Data exactly as returned.
+type exportManifest struct {
+	Format  string   `json:"Format"`
+	VirkNum int      `json:"VirkNum"`
+	From    string   `json:"From"`
+	To      string   `json:"To"`
+	Masked  bool     `json:"Masked"`
+	Columns []string `json:"Columns"`
+	Rows    int      `json:"Rows"`
+	SHA256  string   `json:"SHA256"`
+}
+
+type logBogExport struct {
+	Manifest exportManifest `json:"Manifest"`
+	Data     string         `json:"Data"`
+}
+
+// maskCPR cuts a CPR number to its birth date, DDMMYY****.
+func maskCPR(cprNum int) string {
+	return fmt.Sprintf("%010d", cprNum)[:6] + "****"
+}
+
+// maskCPRNavn keeps the initials of a name.
+func maskCPRNavn(navn string) string {
+	initials := ""
+	for _, part := range strings.Fields(navn) {
+		initials += string([]rune(part)[0]) + "."
+	}
+	return initials
+}
+
+func newExportRow(employee SKATEmployee, mask bool) exportRow {
+	row := exportRow{
+		VirkNum:        employee.VirkNum,
+		DateOfWork:     employee.DateOfWork,
+		CPRHash:        employee.CPRHash,
+		CPRNavn:        employee.CPRNavn,
+		NoOfHours:      employee.NoOfHours,
+		StartTime:      employee.StartTime,
+		EndTime:        employee.EndTime,
+		Comment:        employee.Comment,
+		Retracted:      employee.Retracted,
+		RetractReason:  employee.RetractReason,
+		RetractedAt:    employee.RetractedAt,
+		RuleViolations: employee.RuleViolations,
+		Version:        employee.Version,
+	}
+	if row.RuleViolations == nil {
+		row.RuleViolations = []string{}
+	}
+	// an entry read without the key, or erased, has no CPRNum to show
+	if employee.CPRNum != 0 {
+		row.CPRNum = fmt.Sprintf("%010d", employee.CPRNum)
+	}
+	// CPRHash links the rows of one person across exports, and free text may name anyone
+	if mask {
+		if employee.CPRNum != 0 {
+			row.CPRNum = maskCPR(employee.CPRNum)
+		}
+		row.CPRNavn = maskCPRNavn(employee.CPRNavn)
+		row.CPRHash = ""
+		row.Comment = ""
+		row.RetractReason = ""
+	}
+	return row
+}
+
+// ============================================================================================================================
+// Export LogBog - every entry of a VirkNum in a date range, retracted ones included, as CSV or JSON Lines with a manifest
+// ============================================================================================================================
+func (t *SimpleChaincode) exportLogBog(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	//     0        1      2       3                    4
+	// "VirkNum", "from", "to", "format", "mask personal data (optional)"
+	virkNum, _ := parseVirkNum(args[0])
+	window, err := newDateWindow(args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	manifest := exportManifest{
+		Format:  args[3],
+		VirkNum: virkNum,
+		From:    window.From.Format(dateOfWorkFormat),
+		To:      window.To.Format(dateOfWorkFormat),
+		Columns: exportColumns,
+	}
+	if len(args) > 4 && len(args[4]) > 0 {
+		manifest.Masked, _ = strconv.ParseBool(args[4])
+	}
+	fmt.Println("exporting Virk Num:" + strconv.Itoa(virkNum) + " from:" + manifest.From + " to:" + manifest.To + " as " + manifest.Format)
+
+	// the VirkNum index orders the entries by CPRNum, then DateOfWork
+	candidates, err := findLogBogEntries(stub, "", strconv.Itoa(virkNum), window)
+	if err != nil {
+		return nil, err
+	}
+	rows := []exportRow{}
+	for _, employee := range candidates {
+		if window.contains(employee.DateOfWork) {
+			rows = append(rows, newExportRow(openLogBogEntry(stub, employee), manifest.Masked))
+		}
+	}
+
+	var data bytes.Buffer
+	if manifest.Format == exportCSV {
+		writer := csv.NewWriter(&data)
+		writer.Write(exportColumns)
+		for _, row := range rows {
+			writer.Write(row.values())
+		}
+		writer.Flush()
+		err = writer.Error()
+	} else {
+		encoder := json.NewEncoder(&data)
+		for _, row := range rows {
+			err = encoder.Encode(row)
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(data.Bytes())
+	manifest.Rows = len(rows)
+	manifest.SHA256 = hex.EncodeToString(digest[:])
+	fmt.Println("exported " + strconv.Itoa(manifest.Rows) + " entries")
+	return json.Marshal(logBogExport{Manifest: manifest, Data: data.String()})
+}
+
+func checkExportFormat(value string) error {
+	if value != exportCSV && value != exportJSONL {
+		return errors.New("must be " + exportCSV + " or " + exportJSONL + ": " + value)
+	}
+	return nil
+}
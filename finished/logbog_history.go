@@ -0,0 +1,139 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Every change to an entry appends a version under
+//
+//	LogBog_hist_<CPRNum>_<VirkNum>_<DateOfWork>_<Version, zero padded>
+//
+// so the chain reads back in order with a single range scan. Versions are
+// never rewritten; the entry's Version field points at the newest one.
+const logBogHistoryPrefix = "LogBog_hist_"
+
+// Operations recorded in logBogVersion.Operation.
+const (
+	opAdd     = "add"
+	opUpsert  = "upsert"
+	opUpdate  = "update"
+	opRetract = "retract"
+	opDelete  = "delete"
+	opMigrate = "migrate"
+	opErase   = "erase"
+)
+
+// logBogVersion is one link in an entry's version chain.
+type logBogVersion struct {
+	Version   int          `json:"Version"`
+	Operation string       `json:"Operation"`
+	TxID      string       `json:"TxID"`
+	Timestamp string       `json:"Timestamp"`
+	Submitter string       `json:"Submitter"`
+	Record    SKATEmployee `json:"Record"`
+}
+
+func logBogHistoryPrefixFor(employee SKATEmployee) string {
+	return logBogHistoryPrefix + employeeCPRKey(employee) + "_" + strconv.Itoa(employee.VirkNum) + "_" + employee.DateOfWork + "_"
+}
+
+func logBogHistoryKey(employee SKATEmployee) string {
+	return logBogHistoryPrefixFor(employee) + fmt.Sprintf("%08d", employee.Version)
+}
+
+// lastLogBogVersion returns the newest version in an entry's history, 0 if it
+// has none. A hard-deleted entry that is added again continues its old chain.
+func lastLogBogVersion(stub shim.ChaincodeStubInterface, employee SKATEmployee) (int, error) {
+	keys, _, err := rangeLogBog(stub, logBogHistoryPrefixFor(employee))
+	if err != nil || len(keys) == 0 {
+		return 0, err
+	}
+	last := keys[len(keys)-1]
+	return strconv.Atoi(last[len(last)-8:])
+}
+
+// appendLogBogVersion records employee, already carrying its new Version, as
+// the outcome of operation in the current transaction.
+func appendLogBogVersion(stub shim.ChaincodeStubInterface, operation string, employee SKATEmployee) error {
+	version := logBogVersion{
+		Version:   employee.Version,
+		Operation: operation,
+		TxID:      stub.GetTxID(),
+		Timestamp: txTimeString(stub),
+		Submitter: callerIdentity(stub),
+		Record:    employee,
+	}
+	version.Record.SchemaVersion = logBogSchemaVersion
+	jsonAsBytes, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+	key := logBogHistoryKey(employee)
+	err = stub.PutState(key, jsonAsBytes)
+	if err != nil {
+		return newStateError(key, err)
+	}
+	return nil
+}
+
+// ============================================================================================================================
+// History Employee - every version of one entry, oldest first
+// ============================================================================================================================
+func (t *SimpleChaincode) historySKATEmployee(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var employee SKATEmployee
+	var err error
+
+	//     0         1            2
+	// "CPRNum", "VirkNum", "DateOfWork"
+	employee.CPRNum, _ = parseCPR(args[0])
+	employee.VirkNum, _ = parseVirkNum(args[1])
+	employee.DateOfWork, _ = normaliseDateOfWork(args[2])
+	employee, err = sealLogBogEntry(stub, employee)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := logBogHistoryPrefixFor(employee)
+	keys, values, err := rangeLogBog(stub, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, newLogBogError(errCodeNotFound, logBogEntryKey(employee), "No history found for "+args[0]+" "+args[1]+" "+employee.DateOfWork)
+	}
+
+	versions := []logBogVersion{}
+	for i, value := range values {
+		var version logBogVersion
+		err = json.Unmarshal(value, &version)
+		if err == nil {
+			err = upgradeLogBogEntry(&version.Record)
+		}
+		if err != nil {
+			return nil, newDecodeError(keys[i], err)
+		}
+		version.Record = openLogBogEntry(stub, version.Record)
+		versions = append(versions, version)
+	}
+	return json.Marshal(versions)
+}
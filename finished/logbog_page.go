@@ -0,0 +1,153 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// maxPageSize caps the records returned by one paged query.
+const maxPageSize = 500
+
+// logBogPage is one page of a paged searchLogBog. NextToken is passed back to
+// fetch the following page and is empty on the last one. Total counts the
+// matches across all pages as of the first page; later pages repeat it rather
+// than count again.
+type logBogPage struct {
+	Records   []SKATEmployee `json:"records"`
+	NextToken string         `json:"nextToken"`
+	Total     int            `json:"total"`
+}
+
+// A page token is the key the last record of the previous page was found
+// under. Pages resume after that key rather than at an offset, so entries added
+// or removed between requests never shift a record onto two pages or none.
+func encodePageToken(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodePageToken(token string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(key) == 0 {
+		return "", errors.New("must be a nextToken returned by an earlier page: " + token)
+	}
+	return string(key), nil
+}
+
+// A searchLogBog token also carries the total of the first page, after a NUL,
+// which no key contains.
+func encodeSearchToken(key string, total int) string {
+	return encodePageToken(key + "\x00" + strconv.Itoa(total))
+}
+
+func decodeSearchToken(token string) (string, int, error) {
+	value, err := decodePageToken(token)
+	if err != nil {
+		return "", 0, err
+	}
+	sep := strings.LastIndex(value, "\x00")
+	if sep < 1 {
+		return "", 0, errors.New("must be a nextToken returned by an earlier page: " + token)
+	}
+	total, err := strconv.Atoi(value[sep+1:])
+	if err != nil || total < 0 {
+		return "", 0, errors.New("must be a nextToken returned by an earlier page: " + token)
+	}
+	return value[:sep], total, nil
+}
+
+// pageLogBog returns the first pageSize entries of scan after token that match.
+// A later page reads from the token's key and stops at the first match past
+// the page, so its cost follows pageSize rather than the number of matches.
+// Only the first page reads on to count the total.
+func pageLogBog(stub shim.ChaincodeStubInterface, scan logBogScan, match func(SKATEmployee) bool, pageSize int, token string) (logBogPage, error) {
+	page := logBogPage{Records: []SKATEmployee{}}
+
+	startKey := scan.StartKey
+	if len(token) > 0 {
+		after, total, err := decodeSearchToken(token)
+		if err != nil {
+			return page, newFieldError("pageToken", "pageToken "+err.Error())
+		}
+		if after >= startKey {
+			startKey = after + "\x00"
+		}
+		page.Total = total
+	}
+	if startKey >= scan.EndKey {
+		return page, nil
+	}
+
+	iter, err := stub.RangeQueryState(startKey, scan.EndKey)
+	if err != nil {
+		return page, newStateError(startKey, err)
+	}
+	defer iter.Close()
+
+	matched := 0
+	lastKey := ""
+	for iter.HasNext() {
+		key, value, err := iter.Next()
+		if err != nil {
+			return page, newStateError(startKey, err)
+		}
+		employee, found, err := resolveLogBogScan(stub, scan, key, value)
+		if err != nil {
+			return page, err
+		}
+		if !found || !match(employee) {
+			continue
+		}
+		matched++
+		if len(page.Records) < pageSize {
+			page.Records = append(page.Records, employee)
+			lastKey = key
+		} else if len(token) > 0 {
+			break
+		}
+	}
+	if len(token) == 0 {
+		page.Total = matched
+	}
+	if matched > len(page.Records) {
+		page.NextToken = encodeSearchToken(lastKey, page.Total)
+	}
+	return page, nil
+}
+
+func parsePageSize(value string) (int, error) {
+	pageSize, err := strconv.Atoi(value)
+	if err != nil || pageSize < 1 || pageSize > maxPageSize {
+		return 0, errors.New("must be a whole number from 1 to " + strconv.Itoa(maxPageSize) + ": " + value)
+	}
+	return pageSize, nil
+}
+
+func checkPageSize(value string) error {
+	_, err := parsePageSize(value)
+	return err
+}
+
+func checkPageToken(value string) error {
+	_, _, err := decodeSearchToken(value)
+	return err
+}
@@ -0,0 +1,141 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// logBogPatch is the set of fields updateLogBog may change on an entry. A nil
+// field is left as it is.
+type logBogPatch struct {
+	NoOfHours *float64
+	CPRNavn   *string
+	Comment   *string
+	StartTime *string
+	EndTime   *string
+}
+
+// parseLogBogPatch reads updateLogBog's last argument. A JSON object such as
+// {"NoOfHours": 7.5, "Comment": "sick"} patches the named fields; any other
+// string replaces the Comment, as updateLogBog always did. NoOfHours may also
+// be a string such as "7:30", and StartTime and EndTime are cleared with "".
+func parseLogBogPatch(value string) (logBogPatch, error) {
+	var patch logBogPatch
+
+	if !strings.HasPrefix(strings.TrimSpace(value), "{") {
+		patch.Comment = &value
+		return patch, nil
+	}
+
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal([]byte(value), &fields)
+	if err != nil {
+		return patch, newFieldError("patch", "patch must be a JSON object: "+err.Error())
+	}
+	if len(fields) == 0 {
+		return patch, newFieldError("patch", "patch must change at least one of NoOfHours, CPRNavn, Comment, StartTime, EndTime")
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		raw := fields[name]
+		switch name {
+		case "NoOfHours":
+			value := string(raw)
+			var text string
+			if json.Unmarshal(raw, &text) == nil {
+				value = text
+			}
+			hours, err := parseHours(value)
+			if err != nil {
+				return patch, newFieldError(name, name+" "+err.Error())
+			}
+			patch.NoOfHours = &hours
+		case "CPRNavn":
+			var navn string
+			if json.Unmarshal(raw, &navn) != nil || len(navn) == 0 {
+				return patch, newFieldError(name, name+" must be a non-empty string")
+			}
+			navn = strings.ToLower(navn)
+			patch.CPRNavn = &navn
+		case "Comment", "Comments":
+			var comment string
+			if json.Unmarshal(raw, &comment) != nil {
+				return patch, newFieldError("Comment", "Comment must be a string")
+			}
+			patch.Comment = &comment
+		case "StartTime", "EndTime":
+			var shiftTime string
+			if json.Unmarshal(raw, &shiftTime) != nil {
+				return patch, newFieldError(name, name+" must be a string")
+			}
+			if len(shiftTime) > 0 {
+				err = checkShiftTime(shiftTime)
+				if err != nil {
+					return patch, newFieldError(name, name+" "+err.Error())
+				}
+			}
+			if name == "StartTime" {
+				patch.StartTime = &shiftTime
+			} else {
+				patch.EndTime = &shiftTime
+			}
+		default:
+			return patch, newFieldError(name, name+" cannot be updated, only NoOfHours, CPRNavn, Comment, StartTime and EndTime can")
+		}
+	}
+	return patch, nil
+}
+
+// apply returns a copy of employee with the patched fields replaced.
+func (p logBogPatch) apply(employee SKATEmployee) SKATEmployee {
+	if p.NoOfHours != nil {
+		employee.NoOfHours = *p.NoOfHours
+	}
+	if p.CPRNavn != nil {
+		employee.CPRNavn = *p.CPRNavn
+	}
+	if p.Comment != nil {
+		employee.Comment = *p.Comment
+	}
+	if p.StartTime != nil {
+		employee.StartTime = *p.StartTime
+	}
+	if p.EndTime != nil {
+		employee.EndTime = *p.EndTime
+	}
+	return employee
+}
+
+// derivesHours reports whether the patched entry takes NoOfHours from its
+// shift: the patch sets a shift time but no NoOfHours.
+func (p logBogPatch) derivesHours() bool {
+	return p.NoOfHours == nil && ((p.StartTime != nil && len(*p.StartTime) > 0) || (p.EndTime != nil && len(*p.EndTime) > 0))
+}
+
+func checkLogBogPatch(value string) error {
+	_, err := parseLogBogPatch(value)
+	return err
+}
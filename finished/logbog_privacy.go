@@ -0,0 +1,279 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// A deployment initialised with {"pseudonymise": true} keeps no CPR number or
+// name in clear in world state:
+//
+//   - every entry, index and history key carries CPRHash, an HMAC-SHA256 of the
+//     CPR number, in place of CPRNum, so an exact CPR lookup is still one prefix scan
+//   - the record keeps CPRNum and CPRNavn only AES-GCM encrypted, as CPRNumEnc
+//     and CPRNavnEnc
+//
+// Both keys are derived from a 32 byte secret the client passes with each
+// transaction in the invocation metadata, as {"logBogKey": "<base64>"}. The
+// secret is never written to state. Functions that must hash a CPRNum or
+// encrypt a name fail with KEY_REQUIRED without it; queries without it return
+// records with the encrypted fields left as they are.
+//
+// Fabric v0.6 keeps the metadata of an invoke in the block with the rest of
+// the transaction, so anyone who can read the blocks of such an invoke can
+// read the secret, and with it every pseudonym and sealed name. Only queries
+// leave no trace. Pseudonymisation therefore keeps personal data out of world
+// state and out of the answers of callers without the secret; it does not
+// protect against readers of the chain unless the network encrypts
+// transactions (security.privacy in core.yaml).
+//
+// The config keeps a check value of the secret, recorded by Init or by the
+// first entry written with it. A different secret fails with KEY_MISMATCH
+// rather than quietly hashing CPR numbers to pseudonyms no entry is stored under.
+const logBogKeyMetadataField = "logBogKey"
+
+type logBogKeys struct {
+	// CPR keys the CPRHash pseudonym
+	CPR []byte
+	// Seal is the AES-256 key for CPRNumEnc and CPRNavnEnc
+	Seal []byte
+	// Check identifies the secret as logBogConfig.KeyCheck
+	Check string
+}
+
+func hmacSum(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// getLogBogKeys derives the keys from the caller's metadata, nil when the
+// caller passed no logBogKey, and checks them against the config.
+func getLogBogKeys(stub shim.ChaincodeStubInterface) (*logBogKeys, error) {
+	keys, err := callerLogBogKeys(stub)
+	if err != nil || keys == nil {
+		return keys, err
+	}
+	config, err := getLogBogConfig(stub)
+	if err != nil {
+		return nil, err
+	}
+	err = keys.checkAgainst(config)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// callerLogBogKeys derives the keys from the caller's metadata without checking them.
+func callerLogBogKeys(stub shim.ChaincodeStubInterface) (*logBogKeys, error) {
+	metadata, err := stub.GetCallerMetadata()
+	if err != nil || len(metadata) == 0 {
+		return nil, nil
+	}
+	var fields map[string]string
+	if json.Unmarshal(metadata, &fields) != nil || len(fields[logBogKeyMetadataField]) == 0 {
+		return nil, nil
+	}
+	secret, err := base64.StdEncoding.DecodeString(fields[logBogKeyMetadataField])
+	if err != nil || len(secret) != 32 {
+		return nil, newFieldError(logBogKeyMetadataField, logBogKeyMetadataField+" must be 32 bytes, base64 encoded")
+	}
+	return &logBogKeys{
+		CPR:   hmacSum(secret, "LogBog CPRHash"),
+		Seal:  hmacSum(secret, "LogBog seal"),
+		Check: hex.EncodeToString(hmacSum(secret, "LogBog key check")),
+	}, nil
+}
+
+func (k *logBogKeys) checkAgainst(config logBogConfig) error {
+	if len(config.KeyCheck) > 0 && !hmac.Equal([]byte(config.KeyCheck), []byte(k.Check)) {
+		return &logBogError{Code: errCodeKeyMismatch, Field: logBogKeyMetadataField, Message: logBogKeyMetadataField + " is not the key this deployment pseudonymises with"}
+	}
+	return nil
+}
+
+// recordLogBogKeyCheck stores the check value of the caller's key in the
+// config if none is stored yet, so the first key entries are written under
+// is the only one accepted from then on.
+func recordLogBogKeyCheck(stub shim.ChaincodeStubInterface) error {
+	config, err := getLogBogConfig(stub)
+	if err != nil || !config.Pseudonymise || len(config.KeyCheck) > 0 {
+		return err
+	}
+	keys, err := callerLogBogKeys(stub)
+	if err != nil || keys == nil {
+		return err
+	}
+	config.KeyCheck = keys.Check
+	err = putLogBogConfig(stub, config)
+	if err != nil {
+		return newStateError(logBogConfigKey, err)
+	}
+	return nil
+}
+
+func requireLogBogKeys(stub shim.ChaincodeStubInterface) (*logBogKeys, error) {
+	keys, err := getLogBogKeys(stub)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		return nil, &logBogError{Code: errCodeKeyRequired, Message: "This deployment pseudonymises CPR numbers; pass " + logBogKeyMetadataField + " in the invocation metadata"}
+	}
+	return keys, nil
+}
+
+func (k *logBogKeys) pseudonymiseCPR(cprNum int) string {
+	return hex.EncodeToString(hmacSum(k.CPR, strconv.Itoa(cprNum)))
+}
+
+// seal encrypts plaintext for field of the entry under entryKey. The nonce is
+// derived from the transaction, entry and plaintext so every peer computes the
+// same ciphertext; the entry key is bound in as additional data.
+func (k *logBogKeys) seal(stub shim.ChaincodeStubInterface, field, entryKey, plaintext string) (string, error) {
+	block, err := aes.NewCipher(k.Seal)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := hmacSum(k.Seal, stub.GetTxID()+"_"+field+"_"+entryKey+"_"+plaintext)[:gcm.NonceSize()]
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(field+"_"+entryKey))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (k *logBogKeys) open(field, entryKey, sealed string) (string, error) {
+	block, err := aes.NewCipher(k.Seal)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("sealed value too short")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(field+"_"+entryKey))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// sealLogBogEntry returns employee as it is stored: unchanged unless the
+// deployment pseudonymises, otherwise with CPRNum and CPRNavn moved into their
+// encrypted fields. Sealing an already sealed entry only encrypts a CPRNavn
+// set on it since.
+func sealLogBogEntry(stub shim.ChaincodeStubInterface, employee SKATEmployee) (SKATEmployee, error) {
+	config, err := getLogBogConfig(stub)
+	if err != nil || !config.Pseudonymise {
+		return employee, err
+	}
+	if employee.CPRNum == 0 && len(employee.CPRNavn) == 0 {
+		return employee, nil
+	}
+	keys, err := requireLogBogKeys(stub)
+	if err != nil {
+		return employee, err
+	}
+
+	if employee.CPRNum != 0 {
+		employee.CPRHash = keys.pseudonymiseCPR(employee.CPRNum)
+	}
+	entryKey := logBogEntryKey(employee)
+	if employee.CPRNum != 0 {
+		employee.CPRNumEnc, err = keys.seal(stub, "CPRNum", entryKey, strconv.Itoa(employee.CPRNum))
+		if err != nil {
+			return employee, err
+		}
+		employee.CPRNum = 0
+	}
+	if len(employee.CPRNavn) > 0 {
+		employee.CPRNavnEnc, err = keys.seal(stub, "CPRNavn", entryKey, employee.CPRNavn)
+		if err != nil {
+			return employee, err
+		}
+		employee.CPRNavn = ""
+	}
+	return employee, nil
+}
+
+// openLogBogEntry decrypts a sealed entry for output when the caller passed
+// the key, and returns it unchanged otherwise.
+func openLogBogEntry(stub shim.ChaincodeStubInterface, employee SKATEmployee) SKATEmployee {
+	if len(employee.CPRHash) == 0 {
+		return employee
+	}
+	keys, err := getLogBogKeys(stub)
+	if err != nil || keys == nil {
+		return employee
+	}
+	entryKey := logBogEntryKey(employee)
+	if employee.CPRNum == 0 && len(employee.CPRNumEnc) > 0 {
+		cprNum, err := keys.open("CPRNum", entryKey, employee.CPRNumEnc)
+		if err == nil {
+			employee.CPRNum, _ = strconv.Atoi(cprNum)
+		}
+	}
+	if len(employee.CPRNavn) == 0 && len(employee.CPRNavnEnc) > 0 {
+		cprNavn, err := keys.open("CPRNavn", entryKey, employee.CPRNavnEnc)
+		if err == nil {
+			employee.CPRNavn = cprNavn
+		}
+	}
+	if employee.CPRNum != 0 {
+		employee.CPRNumEnc = ""
+	}
+	if len(employee.CPRNavn) > 0 {
+		employee.CPRNavnEnc = ""
+	}
+	return employee
+}
+
+// logBogCPRKey returns the CPRNum part of the keys for a normalised CPRNum
+// argument: the number itself, or its CPRHash when the deployment pseudonymises.
+func logBogCPRKey(stub shim.ChaincodeStubInterface, cprNum string) (string, error) {
+	config, err := getLogBogConfig(stub)
+	if err != nil || !config.Pseudonymise || len(cprNum) == 0 {
+		return cprNum, err
+	}
+	keys, err := requireLogBogKeys(stub)
+	if err != nil {
+		return "", err
+	}
+	value, _ := strconv.Atoi(cprNum)
+	return keys.pseudonymiseCPR(value), nil
+}
@@ -0,0 +1,193 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Dimensions reportHours can group by. At most one period may be chosen.
+const (
+	groupByCPR   = "cpr"
+	groupByVirk  = "virk"
+	groupByDay   = "day"
+	groupByWeek  = "week"
+	groupByMonth = "month"
+)
+
+// hoursGroup totals the entries sharing the dimensions grouped by; the
+// others are left empty. An entry whose CPRNum is not readable, pseudonymised
+// without the key or erased, is grouped by its CPRHash instead.
+type hoursGroup struct {
+	CPRNum          int     `json:"CPRNum,omitempty"`
+	CPRHash         string  `json:"CPRHash,omitempty"`
+	VirkNum         int     `json:"VirkNum,omitempty"`
+	Period          string  `json:"Period,omitempty"`
+	TotalHours      float64 `json:"TotalHours"`
+	Entries         int     `json:"Entries"`
+	FirstDateOfWork string  `json:"FirstDOW"`
+	LastDateOfWork  string  `json:"LastDOW"`
+}
+
+type hoursReport struct {
+	GroupBy []string     `json:"GroupBy"`
+	From    string       `json:"From"`
+	To      string       `json:"To"`
+	Groups  []hoursGroup `json:"Groups"`
+}
+
+// parseGroupBy splits a comma separated groupBy argument; "" reports a single grand total.
+func parseGroupBy(value string) ([]string, error) {
+	groupBy := []string{}
+	if len(value) == 0 {
+		return groupBy, nil
+	}
+	seen := map[string]bool{}
+	periods := 0
+	for _, dimension := range strings.Split(value, ",") {
+		dimension = strings.ToLower(strings.TrimSpace(dimension))
+		switch dimension {
+		case groupByCPR, groupByVirk:
+		case groupByDay, groupByWeek, groupByMonth:
+			periods++
+		default:
+			return nil, errors.New("must list cpr, virk and one of day, week or month: " + value)
+		}
+		if seen[dimension] || periods > 1 {
+			return nil, errors.New("must list cpr, virk and one of day, week or month: " + value)
+		}
+		seen[dimension] = true
+		groupBy = append(groupBy, dimension)
+	}
+	return groupBy, nil
+}
+
+func checkGroupBy(value string) error {
+	_, err := parseGroupBy(value)
+	return err
+}
+
+// reportPeriod names the day, ISO week or month dateOfWork falls in.
+func reportPeriod(period, dateOfWork string) string {
+	date, err := parseDateOfWork(dateOfWork)
+	if err != nil {
+		return dateOfWork
+	}
+	switch period {
+	case groupByWeek:
+		year, week := date.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	case groupByMonth:
+		return date.Format("2006-01")
+	}
+	return date.Format(dateOfWorkFormat)
+}
+
+// ============================================================================================================================
+// Report Hours - total NoOfHours, entry count and first/last DateOfWork per group over a date range, retracted entries left out
+// ============================================================================================================================
+func (t *SimpleChaincode) reportHours(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var cprNo, virkNo string
+	var err error
+
+	//     0          1       2          3                      4
+	// "groupBy", "from", "to", "CPRNum (optional)", "VirkNum (optional)"
+	groupBy, _ := parseGroupBy(args[0])
+	window, err := newDateWindow(args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 3 {
+		cprNo, err = normaliseCPR(args[3])
+		if err != nil {
+			return nil, err
+		}
+		cprNo, err = logBogCPRKey(stub, cprNo)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(args) > 4 {
+		virkNo, err = normaliseVirkNum(args[4])
+		if err != nil {
+			return nil, err
+		}
+	}
+	fmt.Println("reporting hours by " + args[0] + " from:" + args[1] + " to:" + args[2])
+
+	candidates, err := findLogBogEntries(stub, cprNo, virkNo, window)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[string]*hoursGroup{}
+	for _, skatEmployee := range candidates {
+		if skatEmployee.Retracted || !window.contains(skatEmployee.DateOfWork) {
+			continue
+		}
+		skatEmployee = openLogBogEntry(stub, skatEmployee)
+
+		var group hoursGroup
+		for _, dimension := range groupBy {
+			switch dimension {
+			case groupByCPR:
+				group.CPRNum = skatEmployee.CPRNum
+				if group.CPRNum == 0 {
+					group.CPRHash = skatEmployee.CPRHash
+				}
+			case groupByVirk:
+				group.VirkNum = skatEmployee.VirkNum
+			default:
+				group.Period = reportPeriod(dimension, skatEmployee.DateOfWork)
+			}
+		}
+		// zero padded so the groups sort by CPRNum, VirkNum and Period in turn
+		groupKey := fmt.Sprintf("%010d_%s_%08d_%s", group.CPRNum, group.CPRHash, group.VirkNum, group.Period)
+		total, ok := groups[groupKey]
+		if !ok {
+			group.FirstDateOfWork = skatEmployee.DateOfWork
+			group.LastDateOfWork = skatEmployee.DateOfWork
+			groups[groupKey] = &group
+			total = &group
+		}
+		total.TotalHours += skatEmployee.NoOfHours
+		total.Entries++
+		if skatEmployee.DateOfWork < total.FirstDateOfWork {
+			total.FirstDateOfWork = skatEmployee.DateOfWork
+		}
+		if skatEmployee.DateOfWork > total.LastDateOfWork {
+			total.LastDateOfWork = skatEmployee.DateOfWork
+		}
+	}
+
+	groupKeys := make([]string, 0, len(groups))
+	for groupKey := range groups {
+		groupKeys = append(groupKeys, groupKey)
+	}
+	sort.Strings(groupKeys)
+	report := hoursReport{GroupBy: groupBy, From: window.From.Format(dateOfWorkFormat), To: window.To.Format(dateOfWorkFormat), Groups: []hoursGroup{}}
+	for _, groupKey := range groupKeys {
+		report.Groups = append(report.Groups, *groups[groupKey])
+	}
+	return json.Marshal(report)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Working-time rules, checked when an entry is added, upserted or updated.
+// Hours are summed per person across every VirkNum:
+//
+//   - a day may hold at most maxDailyHours, and no more than 24 - minRestHours
+//     so the daily rest period is left
+//   - the ISO weeks of the reference period ending with the entry's week may
+//     average at most maxWeeklyAverageHours
+//
+// In reject mode a violation fails the write with RULE_VIOLATION; in flag mode
+// the entry is written with the violations listed in RuleViolations.
+const (
+	rulesOff    = "off"
+	rulesReject = "reject"
+	rulesFlag   = "flag"
+)
+
+// applyWorkingTimeRules checks employee, about to be written, against the
+// other entries of the same person and returns the violations to flag it with.
+func applyWorkingTimeRules(stub shim.ChaincodeStubInterface, employee SKATEmployee) ([]string, error) {
+	config, err := getLogBogConfig(stub)
+	if err != nil || config.WorkingTimeRules == rulesOff {
+		return nil, err
+	}
+	date, err := parseDateOfWork(employee.DateOfWork)
+	if err != nil {
+		return nil, newFieldError("DateOfWork", "DateOfWork "+err.Error())
+	}
+	// Monday of the first week to Sunday of the entry's week
+	weekday := (int(date.Weekday()) + 6) % 7
+	periodEnd := date.AddDate(0, 0, 6-weekday)
+	periodStart := periodEnd.AddDate(0, 0, 1-7*config.ReferencePeriodWeeks)
+
+	others, err := scanLogBogEntries(stub, logBogEntryPrefix+employeeCPRKey(employee)+"_")
+	if err != nil {
+		return nil, err
+	}
+	key := logBogEntryKey(employee)
+	dailyHours := employee.NoOfHours
+	periodHours := employee.NoOfHours
+	for _, other := range others {
+		if other.Retracted || logBogEntryKey(other) == key {
+			continue
+		}
+		otherDate, err := parseDateOfWork(other.DateOfWork)
+		if err != nil {
+			continue
+		}
+		if otherDate.Equal(date) {
+			dailyHours += other.NoOfHours
+		}
+		if !otherDate.Before(periodStart) && !otherDate.After(periodEnd) {
+			periodHours += other.NoOfHours
+		}
+	}
+
+	violations := []string{}
+	if dailyHours > float64(config.MaxDailyHours) {
+		violations = append(violations, fmt.Sprintf("%s hours on %s exceed the daily maximum of %d", formatHours(dailyHours), employee.DateOfWork, config.MaxDailyHours))
+	} else if dailyHours > float64(24-config.MinRestHours) {
+		violations = append(violations, fmt.Sprintf("%s hours on %s leave less than the minimum rest of %d hours", formatHours(dailyHours), employee.DateOfWork, config.MinRestHours))
+	}
+	if periodHours > float64(config.MaxWeeklyAverageHours*config.ReferencePeriodWeeks) {
+		average := periodHours / float64(config.ReferencePeriodWeeks)
+		violations = append(violations, fmt.Sprintf("%.1f hours per week on average in the %d weeks to %s exceed the maximum of %d", average, config.ReferencePeriodWeeks, periodEnd.Format(dateOfWorkFormat), config.MaxWeeklyAverageHours))
+	}
+	if len(violations) == 0 {
+		return nil, nil
+	}
+	if config.WorkingTimeRules == rulesReject {
+		return nil, &logBogError{Code: errCodeRuleViolation, Field: "NoOfHours", Key: key, Message: strings.Join(violations, "; ")}
+	}
+	fmt.Println("flagging " + key + ": " + strings.Join(violations, "; "))
+	return violations, nil
+}
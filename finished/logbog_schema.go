@@ -0,0 +1,163 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Every stored SKATEmployee carries the SchemaVersion it was written with;
+// records from before schema versioning have none and count as version 0.
+// logBogUpgrades[v] upgrades a decoded record from version v to v+1, and
+// decodeLogBogEntry runs every upgrade a record is behind, so the rest of the
+// chaincode only ever sees the latest schema. migrateLogBog rewrites stored
+// records in batches so the upgrades need not run on every read forever.
+//
+// To change the schema, append an upgrade; never edit or remove one. An
+// upgrade must not change CPRNum, CPRHash, VirkNum or DateOfWork, which the
+// record's keys are built from.
+var logBogUpgrades = []func(employee *SKATEmployee){
+	// 0 -> 1: NoOfHours went from whole hours to hours with minutes, and the
+	// fields after Comment were added. A whole number decodes as the same
+	// float and the new fields start out empty, so there is nothing to convert.
+	func(employee *SKATEmployee) {},
+}
+
+// logBogSchemaVersion is the version every record is written with.
+var logBogSchemaVersion = len(logBogUpgrades)
+
+// upgradeLogBogEntry brings a decoded record up to logBogSchemaVersion.
+func upgradeLogBogEntry(employee *SKATEmployee) error {
+	if employee.SchemaVersion > logBogSchemaVersion {
+		return errors.New("schema version " + strconv.Itoa(employee.SchemaVersion) + " is newer than this chaincode's " + strconv.Itoa(logBogSchemaVersion))
+	}
+	for employee.SchemaVersion < logBogSchemaVersion {
+		logBogUpgrades[employee.SchemaVersion](employee)
+		employee.SchemaVersion++
+	}
+	return nil
+}
+
+// decodeLogBogEntry decodes and upgrades the record stored under key.
+func decodeLogBogEntry(key string, value []byte) (SKATEmployee, error) {
+	var employee SKATEmployee
+	err := json.Unmarshal(value, &employee)
+	if err == nil {
+		err = upgradeLogBogEntry(&employee)
+	}
+	if err != nil {
+		return employee, newDecodeError(key, err)
+	}
+	return employee, nil
+}
+
+// maxMigrateBatch caps the entries one migrateLogBog transaction reads.
+const maxMigrateBatch = 500
+
+type migrateBatch struct {
+	Scanned    int    `json:"Scanned"`
+	Migrated   int    `json:"Migrated"`
+	NextCursor string `json:"NextCursor"`
+}
+
+// ============================================================================================================================
+// Migrate LogBog - rewrite up to batchSize entries stored with an older schema, resuming after cursor.
+// NextCursor is passed back until it comes back empty.
+// ============================================================================================================================
+func (t *SimpleChaincode) migrateLogBog(stub shim.ChaincodeStubInterface, args []string) ([]byte, error) {
+	var batch migrateBatch
+
+	//      0                     1
+	// "batchSize", "cursor (optional)"
+	batchSize, _ := parseBatchSize(args[0])
+	startKey := logBogEntryPrefix
+	if len(args) > 1 && len(args[1]) > 0 {
+		after, err := parseMigrateCursor(args[1])
+		if err != nil {
+			return nil, newFieldError("cursor", "cursor "+err.Error())
+		}
+		startKey = after + "\x00"
+	}
+
+	keys, values, err := rangeLogBogLimit(stub, startKey, logBogEntryPrefix+rangeQueryEnd, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		if !strings.HasPrefix(keys[i], logBogEntryPrefix) {
+			continue
+		}
+		var stored struct {
+			SchemaVersion int `json:"SchemaVersion"`
+		}
+		err = json.Unmarshal(value, &stored)
+		if err != nil {
+			return nil, newDecodeError(keys[i], err)
+		}
+		batch.Scanned++
+		if stored.SchemaVersion == logBogSchemaVersion {
+			continue
+		}
+		employee, err := decodeLogBogEntry(keys[i], value)
+		if err != nil {
+			return nil, err
+		}
+		err = putLogBogEntry(stub, employee)
+		if err != nil {
+			return nil, err
+		}
+		batch.Migrated++
+	}
+	if len(keys) == batchSize {
+		batch.NextCursor = encodePageToken(keys[len(keys)-1])
+	}
+	fmt.Println("migrated " + strconv.Itoa(batch.Migrated) + " of " + strconv.Itoa(batch.Scanned) + " entries to schema version " + strconv.Itoa(logBogSchemaVersion))
+	return json.Marshal(batch)
+}
+
+func parseBatchSize(value string) (int, error) {
+	batchSize, err := strconv.Atoi(value)
+	if err != nil || batchSize < 1 || batchSize > maxMigrateBatch {
+		return 0, errors.New("must be a whole number from 1 to " + strconv.Itoa(maxMigrateBatch) + ": " + value)
+	}
+	return batchSize, nil
+}
+
+// parseMigrateCursor decodes a NextCursor, which must name an entry key.
+func parseMigrateCursor(value string) (string, error) {
+	after, err := decodePageToken(value)
+	if err != nil || !strings.HasPrefix(after, logBogEntryPrefix) {
+		return "", errors.New("must be a NextCursor returned by an earlier batch: " + value)
+	}
+	return after, nil
+}
+
+func checkMigrateCursor(value string) error {
+	_, err := parseMigrateCursor(value)
+	return err
+}
+
+func checkBatchSize(value string) error {
+	_, err := parseBatchSize(value)
+	return err
+}
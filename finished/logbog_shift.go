@@ -0,0 +1,122 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// An entry may record when the shift ran in StartTime and EndTime, given
+// either as RFC 3339 timestamps or as HH:MM wall-clock times on DateOfWork.
+// Wall-clock times are stored as YYYY-MM-DDTHH:MM without a zone, and an
+// EndTime at or before the StartTime ends the next day. NoOfHours is derived
+// from the shift when left empty and otherwise may not exceed it, since
+// breaks are not counted.
+const (
+	wallClockLayout = "2006-01-02T15:04"
+	timeOfDayLayout = "15:04"
+)
+
+// parseShiftTime parses a StartTime or EndTime, returning whether it is a
+// wall-clock time. HH:MM is taken as a time on dateOfWork.
+func parseShiftTime(value string, dateOfWork time.Time) (time.Time, bool, error) {
+	if at, err := time.Parse(timeOfDayLayout, value); err == nil {
+		return dateOfWork.Add(time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute), true, nil
+	}
+	if at, err := time.Parse(wallClockLayout, value); err == nil {
+		return at, true, nil
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, false, nil
+	}
+	return time.Time{}, false, errors.New("must be HH:MM or an RFC 3339 timestamp: " + value)
+}
+
+func formatShiftTime(at time.Time, wallClock bool) string {
+	if wallClock {
+		return at.Format(wallClockLayout)
+	}
+	return at.Format(time.RFC3339)
+}
+
+func formatHours(hours float64) string {
+	return fmt.Sprintf("%g", hours)
+}
+
+// checkShift validates and normalises employee's StartTime and EndTime
+// against its DateOfWork and NoOfHours, deriving NoOfHours from them when
+// deriveHours is set. An entry without either time is left as it is.
+func checkShift(employee *SKATEmployee, deriveHours bool) error {
+	if len(employee.StartTime) == 0 && len(employee.EndTime) == 0 {
+		if deriveHours {
+			return newFieldError("NoOfHours", "NoOfHours must be given unless StartTime and EndTime are")
+		}
+		return nil
+	}
+	if len(employee.StartTime) == 0 {
+		return newFieldError("StartTime", "StartTime must be given with EndTime")
+	}
+	if len(employee.EndTime) == 0 {
+		return newFieldError("EndTime", "EndTime must be given with StartTime")
+	}
+
+	dateOfWork, err := parseDateOfWork(employee.DateOfWork)
+	if err != nil {
+		return newFieldError("DateOfWork", "DateOfWork "+err.Error())
+	}
+	start, startWallClock, err := parseShiftTime(employee.StartTime, dateOfWork)
+	if err != nil {
+		return newFieldError("StartTime", "StartTime "+err.Error())
+	}
+	end, endWallClock, err := parseShiftTime(employee.EndTime, dateOfWork)
+	if err != nil {
+		return newFieldError("EndTime", "EndTime "+err.Error())
+	}
+	if startWallClock != endWallClock {
+		return newFieldError("EndTime", "EndTime must be a wall-clock time or a timestamp like StartTime")
+	}
+	if start.Format(dateOfWorkFormat) != employee.DateOfWork {
+		return newFieldError("StartTime", "StartTime "+employee.StartTime+" is not on DateOfWork "+employee.DateOfWork)
+	}
+	if startWallClock && !end.After(start) {
+		end = end.Add(24 * time.Hour)
+	}
+	if !end.After(start) {
+		return newFieldError("EndTime", "EndTime "+employee.EndTime+" is not after StartTime "+employee.StartTime)
+	}
+	shift := end.Sub(start)
+	if shift > 24*time.Hour {
+		return newFieldError("EndTime", "EndTime "+employee.EndTime+" is more than 24 hours after StartTime "+employee.StartTime)
+	}
+	employee.StartTime = formatShiftTime(start, startWallClock)
+	employee.EndTime = formatShiftTime(end, endWallClock)
+
+	shiftHours := float64(shift/time.Minute) / 60
+	if deriveHours {
+		employee.NoOfHours = shiftHours
+	} else if employee.NoOfHours > shiftHours {
+		return newFieldError("NoOfHours", "NoOfHours "+formatHours(employee.NoOfHours)+" exceeds the "+formatHours(shiftHours)+" hours from StartTime to EndTime")
+	}
+	return nil
+}
+
+func checkShiftTime(value string) error {
+	_, _, err := parseShiftTime(value, time.Time{})
+	return err
+}
@@ -0,0 +1,225 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Every LogBog entry is stored under its own key, ordered by CPRNum first so a
+// prefix scan of the entry keys doubles as the CPRNum index. The VirkNum and
+// DateOfWork indexes hold the entry key as their value.
+//
+//	LogBog_entry_<CPRNum>_<VirkNum>_<DateOfWork>  -> SKATEmployee JSON
+//	LogBog_virk_<VirkNum>_<CPRNum>_<DateOfWork>   -> entry key
+//	LogBog_date_<DateOfWork>_<CPRNum>_<VirkNum>   -> entry key
+//
+// A pseudonymising deployment puts the entry's CPRHash where CPRNum is shown.
+const (
+	logBogRepositoryKey   = "SKATEmployeeRepository"
+	logBogEntryPrefix     = "LogBog_entry_"
+	logBogVirkIndexPrefix = "LogBog_virk_"
+	logBogDateIndexPrefix = "LogBog_date_"
+
+	// rangeQueryEnd sorts after every printable character used in keys
+	rangeQueryEnd = "\x7f"
+)
+
+// employeeCPRKey returns the CPRNum part of an entry's keys.
+func employeeCPRKey(employee SKATEmployee) string {
+	if len(employee.CPRHash) > 0 {
+		return employee.CPRHash
+	}
+	return strconv.Itoa(employee.CPRNum)
+}
+
+func logBogEntryKey(employee SKATEmployee) string {
+	return logBogEntryPrefix + employeeCPRKey(employee) + "_" + strconv.Itoa(employee.VirkNum) + "_" + employee.DateOfWork
+}
+
+func logBogVirkIndexKey(employee SKATEmployee) string {
+	return logBogVirkIndexPrefix + strconv.Itoa(employee.VirkNum) + "_" + employeeCPRKey(employee) + "_" + employee.DateOfWork
+}
+
+func logBogDateIndexKey(employee SKATEmployee) string {
+	return logBogDateIndexPrefix + employee.DateOfWork + "_" + employeeCPRKey(employee) + "_" + strconv.Itoa(employee.VirkNum)
+}
+
+// putLogBogEntry writes an entry, in the latest schema, and its index keys. Index keys
+// only depend on CPRNum, VirkNum and DateOfWork, so rewriting an existing entry is idempotent.
+// The first sealed entry records the caller's key check, see recordLogBogKeyCheck.
+func putLogBogEntry(stub shim.ChaincodeStubInterface, employee SKATEmployee) error {
+	if len(employee.CPRHash) > 0 {
+		err := recordLogBogKeyCheck(stub)
+		if err != nil {
+			return err
+		}
+	}
+	employee.SchemaVersion = logBogSchemaVersion
+	jsonAsBytes, err := json.Marshal(employee)
+	if err != nil {
+		return err
+	}
+	key := logBogEntryKey(employee)
+	err = stub.PutState(key, jsonAsBytes)
+	if err != nil {
+		return newStateError(key, err)
+	}
+	err = stub.PutState(logBogVirkIndexKey(employee), []byte(key))
+	if err != nil {
+		return newStateError(logBogVirkIndexKey(employee), err)
+	}
+	err = stub.PutState(logBogDateIndexKey(employee), []byte(key))
+	if err != nil {
+		return newStateError(logBogDateIndexKey(employee), err)
+	}
+	return nil
+}
+
+// delLogBogEntry removes an entry and its index keys.
+func delLogBogEntry(stub shim.ChaincodeStubInterface, employee SKATEmployee) error {
+	for _, key := range []string{logBogEntryKey(employee), logBogVirkIndexKey(employee), logBogDateIndexKey(employee)} {
+		err := stub.DelState(key)
+		if err != nil {
+			return newStateError(key, err)
+		}
+	}
+	return nil
+}
+
+// rangeLogBog returns every key and value stored under prefix, in key order.
+func rangeLogBog(stub shim.ChaincodeStubInterface, prefix string) ([]string, [][]byte, error) {
+	return rangeLogBogBetween(stub, prefix, prefix+rangeQueryEnd)
+}
+
+// rangeLogBogBetween returns every key and value from startKey to endKey, in key order.
+func rangeLogBogBetween(stub shim.ChaincodeStubInterface, startKey, endKey string) ([]string, [][]byte, error) {
+	return rangeLogBogLimit(stub, startKey, endKey, 0)
+}
+
+// rangeLogBogLimit returns the first limit keys and values from startKey to
+// endKey, in key order; a limit of 0 returns them all.
+func rangeLogBogLimit(stub shim.ChaincodeStubInterface, startKey, endKey string, limit int) ([]string, [][]byte, error) {
+	var keys []string
+	var values [][]byte
+
+	iter, err := stub.RangeQueryState(startKey, endKey)
+	if err != nil {
+		return nil, nil, newStateError(startKey, err)
+	}
+	defer iter.Close()
+
+	for iter.HasNext() && (limit == 0 || len(keys) < limit) {
+		key, value, err := iter.Next()
+		if err != nil {
+			return nil, nil, newStateError(startKey, err)
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	return keys, values, nil
+}
+
+// hasLogBogEntries reports whether any entry is stored.
+func hasLogBogEntries(stub shim.ChaincodeStubInterface) (bool, error) {
+	iter, err := stub.RangeQueryState(logBogEntryPrefix, logBogEntryPrefix+rangeQueryEnd)
+	if err != nil {
+		return false, newStateError(logBogEntryPrefix, err)
+	}
+	defer iter.Close()
+	return iter.HasNext(), nil
+}
+
+// logBogScan is the key range a search reads: entry keys, or, if Index is set,
+// index keys whose values are the entry keys.
+type logBogScan struct {
+	StartKey string
+	EndKey   string
+	Index    bool
+}
+
+// entryScan reads the entries whose key starts with prefix.
+func entryScan(prefix string) logBogScan {
+	return logBogScan{StartKey: prefix, EndKey: prefix + rangeQueryEnd}
+}
+
+// indexScan follows the index keys starting with prefix to their entries.
+func indexScan(prefix string) logBogScan {
+	return logBogScan{StartKey: prefix, EndKey: prefix + rangeQueryEnd, Index: true}
+}
+
+// dateIndexScan follows the DateOfWork index keys inside window. Canonical
+// dates sort chronologically, so only the keys between the bounds are read.
+func dateIndexScan(window dateWindow) logBogScan {
+	scan := indexScan(logBogDateIndexPrefix)
+	if !window.From.IsZero() {
+		scan.StartKey = logBogDateIndexPrefix + window.From.Format(dateOfWorkFormat)
+	}
+	if !window.To.IsZero() {
+		scan.EndKey = logBogDateIndexPrefix + window.To.Format(dateOfWorkFormat) + "_" + rangeQueryEnd
+	}
+	return scan
+}
+
+// scanLogBogEntries decodes every entry whose key starts with prefix.
+func scanLogBogEntries(stub shim.ChaincodeStubInterface, prefix string) ([]SKATEmployee, error) {
+	return readLogBogScan(stub, entryScan(prefix))
+}
+
+// readLogBogScan decodes every entry scan finds, in the order of the scanned keys.
+func readLogBogScan(stub shim.ChaincodeStubInterface, scan logBogScan) ([]SKATEmployee, error) {
+	employees := []SKATEmployee{}
+
+	keys, values, err := rangeLogBogBetween(stub, scan.StartKey, scan.EndKey)
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		employee, found, err := resolveLogBogScan(stub, scan, key, values[i])
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			employees = append(employees, employee)
+		}
+	}
+	return employees, nil
+}
+
+// resolveLogBogScan decodes the entry one key of scan stands for. An index key
+// an index still holds after its entry is gone is not found.
+func resolveLogBogScan(stub shim.ChaincodeStubInterface, scan logBogScan, key string, value []byte) (SKATEmployee, bool, error) {
+	var err error
+	if scan.Index {
+		key = string(value)
+		value, err = stub.GetState(key)
+		if err != nil {
+			return SKATEmployee{}, false, newStateError(key, err)
+		}
+		if value == nil {
+			return SKATEmployee{}, false, nil
+		}
+	}
+	employee, err := decodeLogBogEntry(key, value)
+	if err != nil {
+		return SKATEmployee{}, false, err
+	}
+	return employee, true, nil
+}
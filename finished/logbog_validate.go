@@ -0,0 +1,326 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// argSpec describes one positional argument of an Invoke or Query function.
+type argSpec struct {
+	Field string
+	// Optional arguments may be left off the end of the argument list
+	Optional bool
+	// AllowEmpty arguments may be passed as ""; Check is then skipped
+	AllowEmpty bool
+	// Check validates the type and range of a non-empty value, nil accepts
+	// anything. A plain error is reported against Field; a logBogError as is.
+	Check func(value string) error
+}
+
+var (
+	cprArg        = argSpec{Field: "CPRNum", Check: checkCPR}
+	virkArg       = argSpec{Field: "VirkNum", Check: checkVirkNum}
+	dateOfWorkArg = argSpec{Field: "DateOfWork", Check: checkDate}
+)
+
+// logBogArgSpecs lists the arguments of every function Invoke and Query
+// dispatch. Both validate against it before calling the function.
+var logBogArgSpecs = map[string][]argSpec{
+	"init": {
+		{Field: "greeting", AllowEmpty: true},
+		{Field: "config", Optional: true, Check: checkLogBogConfig},
+	},
+	"write": {
+		{Field: "key", Check: checkValueKey},
+		{Field: "value", AllowEmpty: true},
+		{Field: "expectedVersion", Optional: true, AllowEmpty: true, Check: checkExpectedVersion},
+	},
+	"read": {
+		{Field: "key"},
+	},
+	"readVersion": {
+		{Field: "key"},
+	},
+	"addToLogBog":  storeLogBogArgs,
+	"upsertLogBog": storeLogBogArgs,
+	"bulkAddToLogBog": {
+		{Field: "entries"},
+		{Field: "mode", Optional: true, AllowEmpty: true, Check: checkBulkMode},
+	},
+	"checkBulkAddToLogBog": {
+		{Field: "entries"},
+		{Field: "mode", Optional: true, AllowEmpty: true, Check: checkBulkMode},
+	},
+	"updateLogBog": {
+		cprArg,
+		virkArg,
+		dateOfWorkArg,
+		{Field: "patch", AllowEmpty: true, Check: checkLogBogPatch},
+	},
+	"searchLogBog": {
+		{Field: "CPRNum", AllowEmpty: true, Check: checkCPR},
+		{Field: "VirkNum", AllowEmpty: true, Check: checkVirkNum},
+		{Field: "from", Optional: true, AllowEmpty: true, Check: checkDate},
+		{Field: "to", Optional: true, AllowEmpty: true, Check: checkDate},
+		{Field: "includeRetracted", Optional: true, AllowEmpty: true, Check: checkBool},
+		{Field: "pageSize", Optional: true, AllowEmpty: true, Check: checkPageSize},
+		{Field: "pageToken", Optional: true, AllowEmpty: true, Check: checkPageToken},
+	},
+	"reportHours": {
+		{Field: "groupBy", AllowEmpty: true, Check: checkGroupBy},
+		{Field: "from", Check: checkDate},
+		{Field: "to", Check: checkDate},
+		{Field: "CPRNum", Optional: true, AllowEmpty: true, Check: checkCPR},
+		{Field: "VirkNum", Optional: true, AllowEmpty: true, Check: checkVirkNum},
+	},
+	"retractLogBog": {
+		cprArg,
+		virkArg,
+		dateOfWorkArg,
+		{Field: "reason"},
+		{Field: "mode", Optional: true, AllowEmpty: true, Check: checkRetractMode},
+	},
+	"exportLogBog": {
+		virkArg,
+		{Field: "from", Check: checkDate},
+		{Field: "to", Check: checkDate},
+		{Field: "format", Check: checkExportFormat},
+		{Field: "mask", Optional: true, AllowEmpty: true, Check: checkBool},
+	},
+	"historyLogBog": {
+		cprArg,
+		virkArg,
+		dateOfWorkArg,
+	},
+	"migrateLogBogRepository": {},
+	"migrateLogBog": {
+		{Field: "batchSize", Check: checkBatchSize},
+		{Field: "cursor", Optional: true, AllowEmpty: true, Check: checkMigrateCursor},
+	},
+	"eraseSubject": {
+		cprArg,
+		{Field: "reference"},
+	},
+	"bindVirkNum": {
+		{Field: "identity"},
+		virkArg,
+	},
+	"unbindVirkNum": {
+		{Field: "identity"},
+		virkArg,
+	},
+	"getVirkBinding": {
+		{Field: "identity"},
+	},
+}
+
+var storeLogBogArgs = []argSpec{
+	cprArg,
+	virkArg,
+	{Field: "CPRNavn"},
+	dateOfWorkArg,
+	{Field: "NoOfHours", AllowEmpty: true, Check: checkHours},
+	{Field: "Comment", Optional: true, AllowEmpty: true},
+	{Field: "StartTime", Optional: true, AllowEmpty: true, Check: checkShiftTime},
+	{Field: "EndTime", Optional: true, AllowEmpty: true, Check: checkShiftTime},
+}
+
+// validateArgs checks the count of args against specs and each present
+// argument against its spec, returning the first problem found.
+func validateArgs(args []string, specs []argSpec) error {
+	required := 0
+	for _, spec := range specs {
+		if !spec.Optional {
+			required++
+		}
+	}
+	if len(args) < required || len(args) > len(specs) {
+		names := make([]string, len(specs))
+		for i, spec := range specs {
+			names[i] = spec.Field
+			if spec.Optional {
+				names[i] = "[" + spec.Field + "]"
+			}
+		}
+		expecting := strconv.Itoa(required)
+		if required != len(specs) {
+			expecting += " to " + strconv.Itoa(len(specs))
+		}
+		return &logBogError{
+			Code:    errCodeInvalidArgumentCount,
+			Message: "Incorrect number of arguments. Expecting " + expecting + ": " + strings.Join(names, ", "),
+		}
+	}
+
+	for i, value := range args {
+		spec := specs[i]
+		if len(value) == 0 {
+			if spec.AllowEmpty {
+				continue
+			}
+			return newFieldError(spec.Field, spec.Field+" must be a non-empty string")
+		}
+		if spec.Check == nil {
+			continue
+		}
+		err := spec.Check(value)
+		if _, ok := err.(*logBogError); ok {
+			return err
+		}
+		if err != nil {
+			return newFieldError(spec.Field, spec.Field+" "+err.Error())
+		}
+	}
+	return nil
+}
+
+func isDigits(value string) bool {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return len(value) > 0
+}
+
+// parseCPR accepts a Danish CPR number as DDMMYYSSSS or DDMMYY-SSSS.
+func parseCPR(value string) (int, error) {
+	digits := value
+	if len(value) == 11 && value[6] == '-' {
+		digits = value[:6] + value[7:]
+	}
+	if len(digits) != 10 || !isDigits(digits) {
+		return 0, errors.New("must be 10 digits, DDMMYYSSSS or DDMMYY-SSSS: " + value)
+	}
+	day, _ := strconv.Atoi(digits[0:2])
+	month, _ := strconv.Atoi(digits[2:4])
+	if day < 1 || day > 31 || month < 1 || month > 12 {
+		return 0, errors.New("must start with a valid DDMMYY birth date: " + value)
+	}
+	cprNum, _ := strconv.Atoi(digits)
+	return cprNum, nil
+}
+
+// parseVirkNum accepts an 8 digit CVR number, which never starts with 0.
+func parseVirkNum(value string) (int, error) {
+	if len(value) != 8 || !isDigits(value) || value[0] == '0' {
+		return 0, errors.New("must be an 8 digit CVR number: " + value)
+	}
+	virkNum, _ := strconv.Atoi(value)
+	return virkNum, nil
+}
+
+// parseHours accepts the hours worked in one day as a decimal number, with a
+// point or a Danish decimal comma, or as H:MM. Hours are kept to the minute.
+func parseHours(value string) (float64, error) {
+	var minutes int
+
+	value = strings.TrimSpace(value)
+	if i := strings.Index(value, ":"); i >= 0 {
+		// Atoi would take a sign, so both parts are checked to be plain digits first
+		if !isDigits(value[:i]) || len(value[i+1:]) != 2 || !isDigits(value[i+1:]) {
+			return 0, errors.New("must be decimal hours such as 7.5 or H:MM: " + value)
+		}
+		wholeHours, _ := strconv.Atoi(value[:i])
+		extraMinutes, _ := strconv.Atoi(value[i+1:])
+		if extraMinutes > 59 {
+			return 0, errors.New("must be decimal hours such as 7.5 or H:MM: " + value)
+		}
+		minutes = wholeHours*60 + extraMinutes
+	} else {
+		hours, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+		if err != nil || hours != hours {
+			return 0, errors.New("must be decimal hours such as 7.5 or H:MM: " + value)
+		}
+		if hours < 0 || hours > 24 {
+			return 0, errors.New("must be between 0 and 24: " + value)
+		}
+		minutes = int(math.Floor(hours*60 + 0.5))
+	}
+	if minutes < 0 || minutes > 24*60 {
+		return 0, errors.New("must be between 0 and 24: " + value)
+	}
+	return float64(minutes) / 60, nil
+}
+
+// normaliseCPR returns an optional CPRNum argument in the form used in keys.
+func normaliseCPR(value string) (string, error) {
+	if len(value) == 0 {
+		return value, nil
+	}
+	cprNum, err := parseCPR(value)
+	if err != nil {
+		return "", newFieldError("CPRNum", "CPRNum "+err.Error())
+	}
+	return strconv.Itoa(cprNum), nil
+}
+
+// normaliseVirkNum returns an optional VirkNum argument in the form used in keys.
+func normaliseVirkNum(value string) (string, error) {
+	if len(value) == 0 {
+		return value, nil
+	}
+	virkNum, err := parseVirkNum(value)
+	if err != nil {
+		return "", newFieldError("VirkNum", "VirkNum "+err.Error())
+	}
+	return strconv.Itoa(virkNum), nil
+}
+
+func checkCPR(value string) error {
+	_, err := parseCPR(value)
+	return err
+}
+
+func checkVirkNum(value string) error {
+	_, err := parseVirkNum(value)
+	return err
+}
+
+func checkHours(value string) error {
+	_, err := parseHours(value)
+	return err
+}
+
+func checkDate(value string) error {
+	_, err := parseDateOfWork(value)
+	return err
+}
+
+func checkBool(value string) error {
+	_, err := strconv.ParseBool(value)
+	if err != nil {
+		return errors.New("must be true or false: " + value)
+	}
+	return nil
+}
+
+func checkRetractMode(value string) error {
+	if value != retractSoft && value != retractHard {
+		return errors.New("must be " + retractSoft + " or " + retractHard + ": " + value)
+	}
+	return nil
+}
+
+func checkLogBogConfig(value string) error {
+	_, err := parseLogBogConfig(value)
+	return err
+}
@@ -0,0 +1,122 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Every value stored by write, and the greeting stored by Init, carries a
+// version under
+//
+//	LogBog_version_<key>
+//
+// bumped by each write. Version 0 means the key holds nothing; a value put
+// there before versioning, or by something other than write, counts as 1.
+const logBogValueVersionPrefix = "LogBog_version_"
+
+// versionedValue is what readVersion returns and write answers with.
+type versionedValue struct {
+	Key     string `json:"Key"`
+	Value   string `json:"Value"`
+	Version int    `json:"Version"`
+}
+
+func getVersionedValue(stub shim.ChaincodeStubInterface, key string) (versionedValue, error) {
+	current := versionedValue{Key: key}
+	valAsbytes, err := stub.GetState(key)
+	if err != nil {
+		return current, newStateError(key, err)
+	}
+	current.Value = string(valAsbytes)
+	versionKey := logBogValueVersionPrefix + key
+	versionAsBytes, err := stub.GetState(versionKey)
+	if err != nil {
+		return current, newStateError(versionKey, err)
+	}
+	switch {
+	case len(versionAsBytes) > 0:
+		current.Version, err = strconv.Atoi(string(versionAsBytes))
+		if err != nil {
+			return current, newDecodeError(versionKey, err)
+		}
+	case len(valAsbytes) > 0:
+		current.Version = 1
+	}
+	return current, nil
+}
+
+// putVersionedValue stores value under key as the next version. An
+// expectedVersion of -1 writes unconditionally; otherwise the key must be at
+// exactly that version, 0 meaning it must not hold a value yet.
+func putVersionedValue(stub shim.ChaincodeStubInterface, key, value string, expectedVersion int) (versionedValue, error) {
+	current, err := getVersionedValue(stub, key)
+	if err != nil {
+		return current, err
+	}
+	if expectedVersion >= 0 && current.Version != expectedVersion {
+		return current, &logBogError{Code: errCodeVersionConflict, Field: "expectedVersion", Key: key,
+			Message: key + " is at version " + strconv.Itoa(current.Version) + ", not " + strconv.Itoa(expectedVersion)}
+	}
+
+	next := versionedValue{Key: key, Value: value, Version: current.Version + 1}
+	err = stub.PutState(key, []byte(value))
+	if err != nil {
+		return current, newStateError(key, err)
+	}
+	versionKey := logBogValueVersionPrefix + key
+	err = stub.PutState(versionKey, []byte(strconv.Itoa(next.Version)))
+	if err != nil {
+		return current, newStateError(versionKey, err)
+	}
+	return next, nil
+}
+
+// parseExpectedVersion reads write's expectedVersion argument; "" is -1, an unconditional write.
+func parseExpectedVersion(value string) (int, error) {
+	if len(value) == 0 {
+		return -1, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 0 {
+		return 0, errors.New("must be a whole number from 0: " + value)
+	}
+	return version, nil
+}
+
+func checkExpectedVersion(value string) error {
+	_, err := parseExpectedVersion(value)
+	return err
+}
+
+// logBogManagedPrefix starts every key the chaincode keeps itself: entries,
+// indexes, history, bindings, anonymisation receipts, the config and the versions
+// of generic values.
+const logBogManagedPrefix = "LogBog"
+
+// checkValueKey keeps write away from the keys the chaincode manages, which
+// it could only overwrite by breaking their invariants.
+func checkValueKey(value string) error {
+	if strings.HasPrefix(value, logBogManagedPrefix) || value == logBogRepositoryKey {
+		return errors.New("must not be " + logBogRepositoryKey + " or start with " + logBogManagedPrefix + ": " + value)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// profile is the peer and identity commands run against.
+type profile struct {
+	Peer          string `json:"peer"`
+	ChaincodeName string `json:"chaincodeName"`
+	SecureContext string `json:"secureContext"`
+	Metadata      string `json:"metadata,omitempty"`
+}
+
+func defaultProfilePath() string {
+	path := os.Getenv("LOGBOG_PROFILE")
+	if len(path) > 0 {
+		return path
+	}
+	return filepath.Join(os.Getenv("HOME"), ".logbog.json")
+}
+
+func loadProfile(path string) (profile, error) {
+	var p profile
+	jsonAsBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return p, errors.New("cannot read profile: " + err.Error())
+	}
+	err = json.Unmarshal(jsonAsBytes, &p)
+	if err != nil {
+		return p, errors.New(path + ": " + err.Error())
+	}
+	if len(p.Peer) == 0 {
+		return p, errors.New(path + " names no peer")
+	}
+	if len(p.SecureContext) == 0 {
+		return p, errors.New(path + " names no secureContext")
+	}
+	_, err = base64.StdEncoding.DecodeString(p.Metadata)
+	if err != nil {
+		return p, errors.New(path + ": metadata must be base64: " + err.Error())
+	}
+	p.Peer = strings.TrimRight(p.Peer, "/")
+	return p, nil
+}
+
+// client sends JSON-RPC requests to the peer's /chaincode endpoint.
+type client struct {
+	profile profile
+	http    *http.Client
+	id      int
+}
+
+func newClient(p profile) *client {
+	return &client{profile: p, http: &http.Client{Timeout: 2 * time.Minute}}
+}
+
+type ctorMsg struct {
+	Function string   `json:"function"`
+	Args     []string `json:"args"`
+}
+
+type chaincodeParams struct {
+	Type        int `json:"type"`
+	ChaincodeID struct {
+		Name string `json:"name"`
+	} `json:"chaincodeID"`
+	CtorMsg       ctorMsg `json:"ctorMsg"`
+	SecureContext string  `json:"secureContext"`
+	Metadata      string  `json:"metadata,omitempty"`
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  chaincodeParams `json:"params"`
+	ID      int             `json:"id"`
+}
+
+type rpcResponse struct {
+	Result *struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	} `json:"result"`
+	Error *rpcError `json:"error"`
+}
+
+// rpcError is a JSON-RPC error from the peer. When the chaincode failed,
+// Data carries its error, a JSON object with a Code and often a Field.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data"`
+}
+
+func (e *rpcError) Error() string {
+	var chaincodeErr struct {
+		Message string `json:"Error"`
+		Code    string `json:"Code"`
+		Field   string `json:"Field"`
+	}
+	if json.Unmarshal([]byte(e.Data), &chaincodeErr) == nil && len(chaincodeErr.Code) > 0 {
+		message := chaincodeErr.Code + ": " + chaincodeErr.Message
+		if len(chaincodeErr.Field) > 0 {
+			message += " (" + chaincodeErr.Field + ")"
+		}
+		return message
+	}
+	if len(e.Data) > 0 {
+		return e.Message + ": " + e.Data
+	}
+	return e.Message
+}
+
+// invoke submits function and returns the transaction id.
+func (c *client) invoke(function string, args []string) (string, error) {
+	return c.call("invoke", function, args)
+}
+
+// query runs function and returns its result.
+func (c *client) query(function string, args []string) ([]byte, error) {
+	result, err := c.call("query", function, args)
+	return []byte(result), err
+}
+
+func (c *client) call(method, function string, args []string) (string, error) {
+	c.id++
+	request := rpcRequest{JSONRPC: "2.0", Method: method, ID: c.id}
+	request.Params.Type = 1
+	request.Params.ChaincodeID.Name = c.profile.ChaincodeName
+	request.Params.CtorMsg = ctorMsg{Function: function, Args: args}
+	request.Params.SecureContext = c.profile.SecureContext
+	request.Params.Metadata = c.profile.Metadata
+
+	var response rpcResponse
+	err := c.post("/chaincode", request, &response)
+	if err != nil {
+		return "", err
+	}
+	if response.Error != nil {
+		return "", response.Error
+	}
+	if response.Result == nil {
+		return "", errors.New("peer returned neither a result nor an error")
+	}
+	return response.Result.Message, nil
+}
+
+// login logs the profile's secureContext in with enrollSecret.
+func (c *client) login(enrollSecret string) (string, error) {
+	login := map[string]string{"enrollId": c.profile.SecureContext, "enrollSecret": enrollSecret}
+	var response struct {
+		OK    string `json:"OK"`
+		Error string `json:"Error"`
+	}
+	err := c.post("/registrar", login, &response)
+	if err != nil {
+		return "", err
+	}
+	if len(response.Error) > 0 {
+		return "", errors.New(response.Error)
+	}
+	return response.OK, nil
+}
+
+func (c *client) post(path string, body interface{}, response interface{}) error {
+	jsonAsBytes, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Post(c.profile.Peer+path, "application/json", bytes.NewReader(jsonAsBytes))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(response)
+	if err != nil {
+		return errors.New(path + " returned " + resp.Status + " and no JSON: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakePeer answers every /chaincode request with result and records the
+// request it got.
+type fakePeer struct {
+	*httptest.Server
+	request rpcRequest
+	result  string
+	err     *rpcError
+}
+
+func newFakePeer() *fakePeer {
+	peer := &fakePeer{}
+	peer.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&peer.request)
+		response := map[string]interface{}{"jsonrpc": "2.0", "id": peer.request.ID}
+		if peer.err != nil {
+			response["error"] = peer.err
+		} else {
+			response["result"] = map[string]string{"status": "OK", "message": peer.result}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	return peer
+}
+
+func TestCommandsBuildCtorMsg(t *testing.T) {
+	peer := newFakePeer()
+	defer peer.Close()
+	c := newClient(profile{Peer: peer.URL, ChaincodeName: "cc", SecureContext: "acme", Metadata: "a2V5"})
+	var out bytes.Buffer
+	stdout, stderr = &out, &out
+
+	tests := []struct {
+		command  string
+		args     string
+		method   string
+		function string
+		ctorArgs []string
+	}{
+		{"add", "-cpr 0101901234 -virk 12345678 -name Bob -date 2026-01-05 -hours 7,5", "invoke", "addToLogBog",
+			[]string{"0101901234", "12345678", "Bob", "2026-01-05", "7,5"}},
+		{"add", "-cpr 0101901234 -virk 12345678 -name Bob -date 2026-01-05 -start 08:00 -end 16:00", "invoke", "addToLogBog",
+			[]string{"0101901234", "12345678", "Bob", "2026-01-05", "", "", "08:00", "16:00"}},
+		{"upsert", "-cpr 0101901234 -virk 12345678 -name Bob -date 2026-01-05 -hours 7 -comment sick", "invoke", "upsertLogBog",
+			[]string{"0101901234", "12345678", "Bob", "2026-01-05", "7", "sick"}},
+		{"update", "-cpr 0101901234 -virk 12345678 -date 2026-01-05 -hours 6 -comment=", "invoke", "updateLogBog",
+			[]string{"0101901234", "12345678", "2026-01-05", `{"Comment":"","NoOfHours":"6"}`}},
+		{"retract", "-cpr 0101901234 -virk 12345678 -date 2026-01-05 -reason wrong -hard", "invoke", "retractLogBog",
+			[]string{"0101901234", "12345678", "2026-01-05", "wrong", "hard"}},
+		{"search", "-virk 12345678", "query", "searchLogBog", []string{"", "12345678"}},
+		{"search", "-cpr 0101901234 -to 2026-01-31 -retracted", "query", "searchLogBog", []string{"0101901234", "", "", "2026-01-31", "true"}},
+		{"search", "-virk 12345678 -page-size 50 -page-token abc", "query", "searchLogBog", []string{"", "12345678", "", "", "", "50", "abc"}},
+		{"history", "-cpr 0101901234 -virk 12345678 -date 2026-01-05", "query", "historyLogBog", []string{"0101901234", "12345678", "2026-01-05"}},
+		{"read", "-key hello_world", "query", "read", []string{"hello_world"}},
+		{"read", "-key hello_world -version", "query", "readVersion", []string{"hello_world"}},
+		{"write", "-key hello_world -value hej", "invoke", "write", []string{"hello_world", "hej"}},
+		{"write", "-key hello_world -value hej -version 2", "invoke", "write", []string{"hello_world", "hej", "2"}},
+	}
+	for _, test := range tests {
+		peer.result = "[]"
+		if strings.Contains(test.args, "page-size") {
+			peer.result = `{"records":[],"nextToken":"","total":0}`
+		}
+		for _, cmd := range commands {
+			if cmd.name == test.command {
+				err := cmd.run(c, strings.Fields(test.args))
+				if err != nil {
+					t.Errorf("%s %s: %v", test.command, test.args, err)
+				}
+			}
+		}
+		params := peer.request.Params
+		if peer.request.Method != test.method || params.CtorMsg.Function != test.function ||
+			strings.Join(params.CtorMsg.Args, "|") != strings.Join(test.ctorArgs, "|") {
+			t.Errorf("%s %s: sent %s %s %q", test.command, test.args, peer.request.Method, params.CtorMsg.Function, params.CtorMsg.Args)
+		}
+		if params.ChaincodeID.Name != "cc" || params.SecureContext != "acme" || params.Metadata != "a2V5" || params.Type != 1 {
+			t.Errorf("%s: params %+v", test.command, params)
+		}
+	}
+}
+
+func TestCommandsNeedFlags(t *testing.T) {
+	c := newClient(profile{Peer: "http://localhost:0", SecureContext: "acme"})
+	var out bytes.Buffer
+	stdout, stderr = &out, &out
+	tests := []struct {
+		command string
+		args    string
+	}{
+		{"add", "-cpr 0101901234 -virk 12345678 -date 2026-01-05"},
+		{"update", "-cpr 0101901234 -virk 12345678 -date 2026-01-05"},
+		{"retract", "-cpr 0101901234 -virk 12345678 -date 2026-01-05"},
+		{"search", "-retracted"},
+		{"login", ""},
+	}
+	for _, test := range tests {
+		for _, cmd := range commands {
+			if cmd.name == test.command {
+				if err := cmd.run(c, strings.Fields(test.args)); err == nil {
+					t.Errorf("%s %s: no error", test.command, test.args)
+				}
+			}
+		}
+	}
+}
+
+func TestChaincodeErrorIsShown(t *testing.T) {
+	peer := newFakePeer()
+	defer peer.Close()
+	peer.err = &rpcError{Code: -32002, Message: "Invocation failure", Data: `{"Error":"NoOfHours must be from 0 to 24: 25","Code":"INVALID_ARGUMENT","Field":"NoOfHours"}`}
+	c := newClient(profile{Peer: peer.URL, SecureContext: "acme"})
+	_, err := c.invoke("addToLogBog", nil)
+	if err == nil || err.Error() != "INVALID_ARGUMENT: NoOfHours must be from 0 to 24: 25 (NoOfHours)" {
+		t.Errorf("got %v", err)
+	}
+	peer.err = &rpcError{Code: -32003, Message: "Query failure", Data: "chaincode x is not deployed"}
+	_, err = c.query("read", nil)
+	if err == nil || err.Error() != "Query failure: chaincode x is not deployed" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logbog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := []struct {
+		profile string
+		ok      bool
+	}{
+		{`{"peer":"http://localhost:7050/","chaincodeName":"cc","secureContext":"acme"}`, true},
+		{`{"peer":"http://localhost:7050","secureContext":"acme","metadata":"not base64!"}`, false},
+		{`{"chaincodeName":"cc","secureContext":"acme"}`, false},
+		{`{"peer":"http://localhost:7050"}`, false},
+		{`peer=localhost`, false},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, "profile.json")
+		ioutil.WriteFile(path, []byte(test.profile), 0600)
+		p, err := loadProfile(path)
+		if (err == nil) != test.ok {
+			t.Errorf("%s: %v", test.profile, err)
+		}
+		if test.ok && p.Peer != "http://localhost:7050" {
+			t.Errorf("peer %q", p.Peer)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+// newFlagSet returns the flags of one command, with a usage line naming it.
+func newFlagSet(name, arguments string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: logbog "+name+" "+arguments)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// required reports the first of names left empty.
+func required(flags *flag.FlagSet, names ...string) error {
+	for _, name := range names {
+		if len(flags.Lookup(name).Value.String()) == 0 {
+			return errors.New(flags.Name() + " needs -" + name)
+		}
+	}
+	return nil
+}
+
+// trimArgs drops the empty optional arguments at the end of args, keeping
+// the first min.
+func trimArgs(args []string, min int) []string {
+	for len(args) > min && len(args[len(args)-1]) == 0 {
+		args = args[:len(args)-1]
+	}
+	return args
+}
+
+func runLogin(c *client, args []string) error {
+	flags := newFlagSet("login", "-secret <enrollSecret>")
+	secret := flags.String("secret", "", "enrollSecret of the profile's secureContext")
+	err := flags.Parse(args)
+	if err == nil {
+		err = required(flags, "secret")
+	}
+	if err != nil {
+		return err
+	}
+	message, err := c.login(*secret)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, message)
+	return nil
+}
+
+// addCommand builds addToLogBog's or upsertLogBog's arguments.
+func addCommand(name, function string) func(c *client, args []string) error {
+	return func(c *client, args []string) error {
+		flags := newFlagSet(name, "-cpr <CPRNum> -virk <VirkNum> -name <CPRNavn> -date <DateOfWork> [-hours <NoOfHours>] [-comment <text>] [-start <time> -end <time>]")
+		cpr := flags.String("cpr", "", "CPR number of the employee, e.g. 0101901234")
+		virk := flags.String("virk", "", "VirkNum of the employer, e.g. 12345678")
+		name := flags.String("name", "", "name of the employee")
+		date := flags.String("date", "", "DateOfWork, e.g. 2026-01-05")
+		hours := flags.String("hours", "", "NoOfHours, e.g. 7.5, 7,5 or 7:30; left out, it is derived from -start and -end")
+		comment := flags.String("comment", "", "comment on the entry")
+		start := flags.String("start", "", "start of the shift, HH:MM on DateOfWork or RFC3339")
+		end := flags.String("end", "", "end of the shift, HH:MM or RFC3339")
+		err := flags.Parse(args)
+		if err == nil {
+			err = required(flags, "cpr", "virk", "name", "date")
+		}
+		if err != nil {
+			return err
+		}
+		return printInvoke(c.invoke(function, trimArgs([]string{*cpr, *virk, *name, *date, *hours, *comment, *start, *end}, 5)))
+	}
+}
+
+// updatePatchFields maps update's flags to the fields of updateLogBog's patch.
+var updatePatchFields = map[string]string{
+	"name":    "CPRNavn",
+	"hours":   "NoOfHours",
+	"comment": "Comment",
+	"start":   "StartTime",
+	"end":     "EndTime",
+}
+
+func runUpdate(c *client, args []string) error {
+	flags := newFlagSet("update", "-cpr <CPRNum> -virk <VirkNum> -date <DateOfWork> [-name ...] [-hours ...] [-comment ...] [-start ...] [-end ...]")
+	cpr := flags.String("cpr", "", "CPR number of the entry")
+	virk := flags.String("virk", "", "VirkNum of the entry")
+	date := flags.String("date", "", "DateOfWork of the entry")
+	flags.String("name", "", "new name of the employee")
+	flags.String("hours", "", "new NoOfHours")
+	flags.String("comment", "", "new comment; -comment '' clears it")
+	flags.String("start", "", "new start of the shift; -start '' clears it")
+	flags.String("end", "", "new end of the shift; -end '' clears it")
+	err := flags.Parse(args)
+	if err == nil {
+		err = required(flags, "cpr", "virk", "date")
+	}
+	if err != nil {
+		return err
+	}
+
+	// only the flags given are patched, so an empty -comment clears the comment
+	patch := map[string]string{}
+	flags.Visit(func(f *flag.Flag) {
+		field, ok := updatePatchFields[f.Name]
+		if ok {
+			patch[field] = f.Value.String()
+		}
+	})
+	if len(patch) == 0 {
+		return errors.New("update needs at least one of -name, -hours, -comment, -start, -end")
+	}
+	patchJsonAsBytes, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	return printInvoke(c.invoke("updateLogBog", []string{*cpr, *virk, *date, string(patchJsonAsBytes)}))
+}
+
+func runRetract(c *client, args []string) error {
+	flags := newFlagSet("retract", "-cpr <CPRNum> -virk <VirkNum> -date <DateOfWork> -reason <text> [-hard]")
+	cpr := flags.String("cpr", "", "CPR number of the entry")
+	virk := flags.String("virk", "", "VirkNum of the entry")
+	date := flags.String("date", "", "DateOfWork of the entry")
+	reason := flags.String("reason", "", "why the entry is retracted")
+	hard := flags.Bool("hard", false, "delete the entry instead of marking it retracted (admin only)")
+	err := flags.Parse(args)
+	if err == nil {
+		err = required(flags, "cpr", "virk", "date", "reason")
+	}
+	if err != nil {
+		return err
+	}
+	ctorArgs := []string{*cpr, *virk, *date, *reason}
+	if *hard {
+		ctorArgs = append(ctorArgs, "hard")
+	}
+	return printInvoke(c.invoke("retractLogBog", ctorArgs))
+}
+
+func runSearch(c *client, args []string) error {
+	flags := newFlagSet("search", "[-cpr <CPRNum>] [-virk <VirkNum>] [-from <date>] [-to <date>] [-retracted] [-page-size <n> [-page-token <token>]]")
+	cpr := flags.String("cpr", "", "CPR number to search for")
+	virk := flags.String("virk", "", "VirkNum to search for")
+	from := flags.String("from", "", "first DateOfWork to include")
+	to := flags.String("to", "", "last DateOfWork to include")
+	retracted := flags.Bool("retracted", false, "include retracted entries")
+	pageSize := flags.Int("page-size", 0, "return one page of this many entries")
+	pageToken := flags.String("page-token", "", "nextToken of the previous page")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if len(*cpr)+len(*virk)+len(*from)+len(*to) == 0 {
+		return errors.New("search needs at least one of -cpr, -virk, -from, -to")
+	}
+
+	includeRetracted := ""
+	if *retracted {
+		includeRetracted = "true"
+	}
+	size := ""
+	if *pageSize > 0 {
+		size = strconv.Itoa(*pageSize)
+	}
+	result, err := c.query("searchLogBog", trimArgs([]string{*cpr, *virk, *from, *to, includeRetracted, size, *pageToken}, 2))
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printRaw(result)
+	}
+	if *pageSize > 0 {
+		var page entryPage
+		err = json.Unmarshal(result, &page)
+		if err != nil {
+			return err
+		}
+		printEntries(page.Records)
+		fmt.Fprintf(stdout, "%d of %d entries\n", len(page.Records), page.Total)
+		if len(page.NextToken) > 0 {
+			fmt.Fprintln(stdout, "next page: -page-token "+page.NextToken)
+		}
+		return nil
+	}
+	var entries []entry
+	err = json.Unmarshal(result, &entries)
+	if err != nil {
+		return err
+	}
+	printEntries(entries)
+	return nil
+}
+
+func runHistory(c *client, args []string) error {
+	flags := newFlagSet("history", "-cpr <CPRNum> -virk <VirkNum> -date <DateOfWork>")
+	cpr := flags.String("cpr", "", "CPR number of the entry")
+	virk := flags.String("virk", "", "VirkNum of the entry")
+	date := flags.String("date", "", "DateOfWork of the entry")
+	err := flags.Parse(args)
+	if err == nil {
+		err = required(flags, "cpr", "virk", "date")
+	}
+	if err != nil {
+		return err
+	}
+	result, err := c.query("historyLogBog", []string{*cpr, *virk, *date})
+	if err != nil {
+		return err
+	}
+	return printJSON(result)
+}
+
+func runRead(c *client, args []string) error {
+	flags := newFlagSet("read", "-key <key> [-version]")
+	key := flags.String("key", "", "key to read")
+	version := flags.Bool("version", false, "read the value with its version, to pass to write -version")
+	err := flags.Parse(args)
+	if err == nil {
+		err = required(flags, "key")
+	}
+	if err != nil {
+		return err
+	}
+	function := "read"
+	if *version {
+		function = "readVersion"
+	}
+	result, err := c.query(function, []string{*key})
+	if err != nil {
+		return err
+	}
+	return printJSON(result)
+}
+
+func runWrite(c *client, args []string) error {
+	flags := newFlagSet("write", "-key <key> -value <value> [-version <n>]")
+	key := flags.String("key", "", "key to write")
+	value := flags.String("value", "", "value to write")
+	version := flags.String("version", "", "only write if the key is still at this version as read -version returned it, 0 if it must not exist yet")
+	err := flags.Parse(args)
+	if err == nil {
+		err = required(flags, "key")
+	}
+	if err != nil {
+		return err
+	}
+	ctorArgs := []string{*key, *value}
+	if len(*version) > 0 {
+		ctorArgs = append(ctorArgs, *version)
+	}
+	return printInvoke(c.invoke("write", ctorArgs))
+}
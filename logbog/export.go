@@ -0,0 +1,211 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// exportManifest is written next to every export as <file>.manifest.json.
+// For an XLSX export, SHA256 covers the workbook and SourceSHA256 the CSV
+// the chaincode returned, which the manifest's Rows and Columns describe.
+type exportManifest struct {
+	File         string   `json:"File"`
+	Format       string   `json:"Format"`
+	VirkNum      int      `json:"VirkNum"`
+	From         string   `json:"From"`
+	To           string   `json:"To"`
+	Masked       bool     `json:"Masked"`
+	Columns      []string `json:"Columns"`
+	Rows         int      `json:"Rows"`
+	SHA256       string   `json:"SHA256"`
+	SourceSHA256 string   `json:"SourceSHA256,omitempty"`
+}
+
+// exportNumberColumns are written to XLSX as numbers rather than text.
+var exportNumberColumns = map[string]bool{"VirkNum": true, "NoOfHours": true, "Version": true}
+
+func runExport(c *client, args []string) error {
+	flags := newFlagSet("export", "-virk <VirkNum> -from <date> -to <date> -out <file> [-format csv|jsonl|xlsx] [-mask]")
+	virk := flags.String("virk", "", "VirkNum to export")
+	from := flags.String("from", "", "first DateOfWork to export")
+	to := flags.String("to", "", "last DateOfWork to export")
+	out := flags.String("out", "", "file to write; the manifest goes to <file>.manifest.json")
+	format := flags.String("format", "csv", "csv, jsonl or xlsx")
+	mask := flags.Bool("mask", false, "cut CPRNum to the birth date and CPRNavn to initials, and leave out CPRHash, Comments and RetractReason")
+	err := flags.Parse(args)
+	if err == nil {
+		err = required(flags, "virk", "from", "to", "out")
+	}
+	if err != nil {
+		return err
+	}
+	if *format != "csv" && *format != "jsonl" && *format != "xlsx" {
+		return errors.New("-format must be csv, jsonl or xlsx")
+	}
+
+	source := *format
+	if source == "xlsx" {
+		source = "csv"
+	}
+	result, err := c.query("exportLogBog", trimArgs([]string{*virk, *from, *to, source, strconv.FormatBool(*mask)}, 4))
+	if err != nil {
+		return err
+	}
+	var export struct {
+		Manifest exportManifest `json:"Manifest"`
+		Data     string         `json:"Data"`
+	}
+	err = json.Unmarshal(result, &export)
+	if err != nil {
+		return err
+	}
+	manifest := export.Manifest
+	if sha256Hex([]byte(export.Data)) != manifest.SHA256 {
+		return errors.New("export does not match the SHA256 in its manifest")
+	}
+
+	data := []byte(export.Data)
+	if *format == "xlsx" {
+		records, err := csv.NewReader(strings.NewReader(export.Data)).ReadAll()
+		if err != nil {
+			return err
+		}
+		var workbook bytes.Buffer
+		err = writeXLSX(&workbook, "LogBog "+strconv.Itoa(manifest.VirkNum), records, exportNumberColumns)
+		if err != nil {
+			return err
+		}
+		data = workbook.Bytes()
+		manifest.Format = "xlsx"
+		manifest.SourceSHA256 = manifest.SHA256
+		manifest.SHA256 = sha256Hex(data)
+	}
+	manifest.File = filepath.Base(*out)
+
+	err = ioutil.WriteFile(*out, data, 0600)
+	if err != nil {
+		return err
+	}
+	manifestJsonAsBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(*out+".manifest.json", append(manifestJsonAsBytes, '\n'), 0600)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printRaw(manifestJsonAsBytes)
+	}
+	fmt.Fprintf(stdout, "wrote %d entries to %s, sha256 %s\n", manifest.Rows, *out, manifest.SHA256)
+	return nil
+}
+
+func sha256Hex(data []byte) string {
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
+
+// writeXLSX writes records as the single sheet of a minimal workbook, the
+// first record as the header row. Cells of the numberColumns named in the
+// header are written as numbers where they parse as one. Nothing in the
+// workbook depends on the time it was written, so the same records always
+// give the same file and SHA256.
+func writeXLSX(w io.Writer, sheet string, records [][]string, numberColumns map[string]bool) error {
+	var rows bytes.Buffer
+	for r, record := range records {
+		fmt.Fprintf(&rows, `<row r="%d">`, r+1)
+		for c, value := range record {
+			ref := xlsxColumn(c) + strconv.Itoa(r+1)
+			if r > 0 && c < len(records[0]) && numberColumns[records[0][c]] {
+				if _, err := strconv.ParseFloat(value, 64); err == nil {
+					fmt.Fprintf(&rows, `<c r="%s"><v>%s</v></c>`, ref, value)
+					continue
+				}
+			}
+			if len(value) == 0 {
+				continue
+			}
+			fmt.Fprintf(&rows, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlText(value))
+		}
+		rows.WriteString(`</row>`)
+	}
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + xmlText(sheet) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+		{"xl/worksheets/sheet1.xml", xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<sheetData>` + rows.String() + `</sheetData></worksheet>`},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(f, file.content)
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// xlsxColumn names the zero-based column c as a spreadsheet does: A to Z, AA, AB, ...
+func xlsxColumn(c int) string {
+	name := ""
+	for c++; c > 0; c = (c - 1) / 26 {
+		name = string(rune('A'+(c-1)%26)) + name
+	}
+	return name
+}
+
+func xmlText(value string) string {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(value))
+	return escaped.String()
+}
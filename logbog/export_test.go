@@ -0,0 +1,132 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestXLSXColumn(t *testing.T) {
+	for c, name := range map[int]string{0: "A", 13: "N", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if xlsxColumn(c) != name {
+			t.Errorf("column %d = %s, want %s", c, xlsxColumn(c), name)
+		}
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	records := [][]string{
+		{"VirkNum", "CPRNum", "NoOfHours", "Comments"},
+		{"12345678", "0101901234", "7.5", "late & <tired>"},
+		{"12345678", "0202851234", "", ""},
+	}
+	var first, second bytes.Buffer
+	if err := writeXLSX(&first, "LogBog", records, exportNumberColumns); err != nil {
+		t.Fatal(err)
+	}
+	writeXLSX(&second, "LogBog", records, exportNumberColumns)
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Errorf("the same records gave different workbooks")
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(first.Bytes()), int64(first.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet string
+	names := []string{}
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			content, _ := ioutil.ReadAll(r)
+			sheet = string(content)
+		}
+	}
+	if strings.Join(names, " ") != "[Content_Types].xml _rels/.rels xl/workbook.xml xl/_rels/workbook.xml.rels xl/worksheets/sheet1.xml" {
+		t.Errorf("parts %v", names)
+	}
+	for _, cell := range []string{
+		`<c r="A2"><v>12345678</v></c>`,
+		`<c r="B2" t="inlineStr"><is><t xml:space="preserve">0101901234</t></is></c>`,
+		`<c r="C2"><v>7.5</v></c>`,
+		`<t xml:space="preserve">late &amp; &lt;tired&gt;</t>`,
+		`<row r="3"><c r="A3"><v>12345678</v></c><c r="B3" t="inlineStr">`,
+	} {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("sheet lacks %s", cell)
+		}
+	}
+}
+
+func TestExportWritesManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logbog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	peer := newFakePeer()
+	defer peer.Close()
+	c := newClient(profile{Peer: peer.URL, SecureContext: "auditor"})
+	var out bytes.Buffer
+	stdout, stderr = &out, &out
+
+	data := "VirkNum,DOW,NoOfHours\n12345678,2026-01-05,7.5\n"
+	result, _ := json.Marshal(map[string]interface{}{
+		"Manifest": exportManifest{Format: "csv", VirkNum: 12345678, From: "2026-01-01", To: "2026-01-31", Rows: 1, SHA256: sha256Hex([]byte(data))},
+		"Data":     data,
+	})
+	peer.result = string(result)
+
+	for _, format := range []string{"csv", "xlsx"} {
+		path := filepath.Join(dir, "january."+format)
+		err = runExport(c, []string{"-virk", "12345678", "-from", "2026-01-01", "-to", "2026-01-31", "-format", format, "-mask", "-out", path})
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if args := strings.Join(peer.request.Params.CtorMsg.Args, " "); args != "12345678 2026-01-01 2026-01-31 csv true" {
+			t.Errorf("%s: sent %s", format, args)
+		}
+		written, _ := ioutil.ReadFile(path)
+		var manifest exportManifest
+		manifestJsonAsBytes, _ := ioutil.ReadFile(path + ".manifest.json")
+		json.Unmarshal(manifestJsonAsBytes, &manifest)
+		if manifest.File != "january."+format || manifest.Format != format || manifest.SHA256 != sha256Hex(written) || manifest.Rows != 1 {
+			t.Errorf("%s: manifest %+v", format, manifest)
+		}
+		if format == "csv" && string(written) != data {
+			t.Errorf("csv: wrote %q", written)
+		}
+		if format == "xlsx" && manifest.SourceSHA256 != sha256Hex([]byte(data)) {
+			t.Errorf("xlsx: source sha256 %s", manifest.SourceSHA256)
+		}
+	}
+
+	tampered, _ := json.Marshal(map[string]interface{}{"Manifest": exportManifest{SHA256: sha256Hex([]byte(data))}, "Data": data + "x"})
+	peer.result = string(tampered)
+	err = runExport(c, []string{"-virk", "12345678", "-from", "2026-01-01", "-to", "2026-01-31", "-out", filepath.Join(dir, "x.csv")})
+	if err == nil {
+		t.Errorf("export not matching its SHA256 accepted")
+	}
+}
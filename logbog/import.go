@@ -0,0 +1,235 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// maxImportBatch is the most rows bulkAddToLogBog takes at a time.
+const maxImportBatch = 500
+
+// importBatch is one bulkAddToLogBog call: its entries, the file rows they
+// came from, and the offset that maps the rows the chaincode reports back to
+// the file. A CSV row is a record, which a quoted field can spread over
+// several lines, so lines holds the line each row of a CSV batch starts on.
+type importBatch struct {
+	entries   string
+	firstRow  int
+	lastRow   int
+	rowOffset int
+	lines     []int
+}
+
+// runImport splits a CSV or JSON file into batches and adds each with one
+// bulkAddToLogBog call. A batch is stored whole or not at all, a file only
+// batch by batch, so an import stops at the first rejected batch and says
+// which row to resume from.
+//
+// A peer answers an invoke with its transaction id before running it, so the
+// rows a batch would be rejected for only come back from the
+// checkBulkAddToLogBog query sent ahead of it. That check sees the ledger as
+// committed when it runs, which need not include the batches submitted just
+// before it yet.
+func runImport(c *client, args []string) error {
+	flags := newFlagSet("import", "-file <entries.csv|entries.json> [-batch-size <n>] [-upsert] [-from-row <row>]")
+	file := flags.String("file", "", "CSV file with a header row, or JSON array, of entries")
+	batchSize := flags.Int("batch-size", 200, "rows per bulkAddToLogBog call, at most 500")
+	upsert := flags.Bool("upsert", false, "replace existing entries instead of rejecting them")
+	fromRow := flags.Int("from-row", 0, "skip the rows before this one, to resume an import")
+	err := flags.Parse(args)
+	if err == nil {
+		err = required(flags, "file")
+	}
+	if err != nil {
+		return err
+	}
+	if *batchSize < 1 || *batchSize > maxImportBatch {
+		return fmt.Errorf("-batch-size must be from 1 to %d", maxImportBatch)
+	}
+	data, err := ioutil.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	batches, err := splitImport(data, *batchSize, *fromRow)
+	if err != nil {
+		return errors.New(*file + ": " + err.Error())
+	}
+
+	mode := ""
+	if *upsert {
+		mode = "upsert"
+	}
+	for i, batch := range batches {
+		batchArgs := trimArgs([]string{batch.entries, mode}, 1)
+		_, err = c.query("checkBulkAddToLogBog", batchArgs)
+		if err == nil {
+			var txID string
+			txID, err = c.invoke("bulkAddToLogBog", batchArgs)
+			if err == nil {
+				fmt.Fprintf(stdout, "rows %d-%d submitted as %s\n", batch.firstRow, batch.lastRow, txID)
+				continue
+			}
+		}
+		printRowErrors(err, batch)
+		if i > 0 {
+			fmt.Fprintf(stderr, "the rows before row %d were submitted; rerun with -from-row %d once the rows are fixed\n", batch.firstRow, batch.firstRow)
+		}
+		return err
+	}
+	return nil
+}
+
+// splitImport cuts a CSV or JSON file into batches of batchSize rows, each
+// CSV batch repeating the header, leaving out the rows before fromRow.
+func splitImport(data []byte, batchSize, fromRow int) ([]importBatch, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return splitImportJSON(data, batchSize, fromRow)
+	}
+	return splitImportCSV(data, batchSize, fromRow)
+}
+
+// splitImportCSV numbers rows as the chaincode does, counting the header as
+// row 1, and notes the line of the file each row starts on.
+func splitImportCSV(data []byte, batchSize, fromRow int) ([]importBatch, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	header := strings.SplitN(string(data), "\n", 2)[0]
+	if strings.Count(header, ";") > strings.Count(header, ",") {
+		reader.Comma = ';'
+	}
+	reader.TrimLeadingSpace = true
+	var records [][]string
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+	if len(records) < 2 {
+		return nil, errors.New("has no rows below the header")
+	}
+
+	batches := []importBatch{}
+	for start := 1; start < len(records); start += batchSize {
+		end := start + batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		// records start to end-1 are file rows start+1 to end
+		if end < fromRow {
+			continue
+		}
+		first := start
+		if first+1 < fromRow {
+			first = fromRow - 1
+		}
+		var buffer bytes.Buffer
+		writer := csv.NewWriter(&buffer)
+		writer.Comma = reader.Comma
+		writer.Write(records[0])
+		writer.WriteAll(records[first:end])
+		if err := writer.Error(); err != nil {
+			return nil, err
+		}
+		// the batch's header is its row 1, so its row 2 is file row first+1
+		batches = append(batches, importBatch{entries: buffer.String(), firstRow: first + 1, lastRow: end, rowOffset: first - 1, lines: lines[first:end]})
+	}
+	if len(batches) == 0 {
+		return nil, fmt.Errorf("has no row %d", fromRow)
+	}
+	return batches, nil
+}
+
+// splitImportJSON numbers rows from 1, as the chaincode does.
+func splitImportJSON(data []byte, batchSize, fromRow int) ([]importBatch, error) {
+	var rows []json.RawMessage
+	err := json.Unmarshal(data, &rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("holds no rows")
+	}
+	if fromRow > 1 {
+		if fromRow > len(rows) {
+			return nil, fmt.Errorf("has no row %d", fromRow)
+		}
+		rows = rows[fromRow-1:]
+	} else {
+		fromRow = 1
+	}
+
+	batches := []importBatch{}
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		entries, err := json.Marshal(rows[start:end])
+		if err != nil {
+			return nil, err
+		}
+		offset := fromRow - 1 + start
+		batches = append(batches, importBatch{entries: string(entries), firstRow: offset + 1, lastRow: offset + end - start, rowOffset: offset})
+	}
+	return batches, nil
+}
+
+// printRowErrors lists the rows a rejected batch reports, numbered as in the
+// file, with the line a CSV row starts on.
+func printRowErrors(err error, batch importBatch) {
+	rpcErr, ok := err.(*rpcError)
+	if !ok {
+		return
+	}
+	var rejected struct {
+		Rows []struct {
+			Row     int    `json:"Row"`
+			Message string `json:"Error"`
+			Code    string `json:"Code"`
+			Field   string `json:"Field"`
+		} `json:"Rows"`
+	}
+	if json.Unmarshal([]byte(rpcErr.Data), &rejected) != nil {
+		return
+	}
+	for _, row := range rejected.Rows {
+		field := ""
+		if len(row.Field) > 0 {
+			field = " (" + row.Field + ")"
+		}
+		where := fmt.Sprintf("row %d", batch.rowOffset+row.Row)
+		if i := row.Row - 2; i >= 0 && i < len(batch.lines) {
+			where += fmt.Sprintf(" (line %d)", batch.lines[i])
+		}
+		fmt.Fprintf(stderr, "%s: %s: %s%s\n", where, row.Code, row.Message, field)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSplitImport(t *testing.T) {
+	csvFile := "CPRNum;VirkNum;CPRNavn;DOW;NoOfHours\n" +
+		"0101901234;12345678;Bob;2026-01-01;7,5\n" +
+		"0101901234;12345678;Bob;2026-01-02;7,5\n" +
+		"0101901234;12345678;Bob;2026-01-03;7,5\n" +
+		"0101901234;12345678;Bob;2026-01-04;7,5\n" +
+		"0101901234;12345678;\"Bob; Jr\";2026-01-05;7,5\n"
+	jsonFile := `[{"DOW":"2026-01-01"},{"DOW":"2026-01-02"},{"DOW":"2026-01-03"},{"DOW":"2026-01-04"},{"DOW":"2026-01-05"}]`
+
+	tests := []struct {
+		name    string
+		file    string
+		size    int
+		fromRow int
+		batches string
+	}{
+		{"csv", csvFile, 2, 0, "2-3/0 4-5/2 6-6/4"},
+		{"csv resumed", csvFile, 2, 5, "5-5/3 6-6/4"},
+		{"csv in one", csvFile, 500, 0, "2-6/0"},
+		{"csv with spaces after the separator", strings.Replace(csvFile, ";", "; ", -1), 2, 0, "2-3/0 4-5/2 6-6/4"},
+		{"json", jsonFile, 2, 0, "1-2/0 3-4/2 5-5/4"},
+		{"json resumed", jsonFile, 2, 4, "4-5/3"},
+	}
+	for _, test := range tests {
+		batches, err := splitImport([]byte(test.file), test.size, test.fromRow)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		got := []string{}
+		for _, batch := range batches {
+			got = append(got, strings.Join([]string{strconv.Itoa(batch.firstRow) + "-" + strconv.Itoa(batch.lastRow), strconv.Itoa(batch.rowOffset)}, "/"))
+			if strings.HasPrefix(test.file, "[") {
+				var rows []json.RawMessage
+				if json.Unmarshal([]byte(batch.entries), &rows) != nil || len(rows) != batch.lastRow-batch.firstRow+1 {
+					t.Errorf("%s: batch %s", test.name, batch.entries)
+				}
+				continue
+			}
+			reader := csv.NewReader(strings.NewReader(batch.entries))
+			reader.Comma = ';'
+			records, err := reader.ReadAll()
+			if err != nil || len(records) != batch.lastRow-batch.firstRow+2 || records[0][0] != "CPRNum" {
+				t.Errorf("%s: batch %q", test.name, batch.entries)
+			}
+			// file row n holds 2026-01-0<n-1>, and is the chaincode's row 2 of its batch
+			if records[1][3] != "2026-01-0"+strconv.Itoa(batch.firstRow-1) || batch.rowOffset+2 != batch.firstRow {
+				t.Errorf("%s: batch starts at %s", test.name, records[1][3])
+			}
+		}
+		if strings.Join(got, " ") != test.batches {
+			t.Errorf("%s: got %s, want %s", test.name, strings.Join(got, " "), test.batches)
+		}
+	}
+
+	for _, file := range []string{"CPRNum;VirkNum\n", "[]", `[{"DOW":1}`} {
+		if _, err := splitImport([]byte(file), 10, 0); err == nil {
+			t.Errorf("%q: no error", file)
+		}
+	}
+	if _, err := splitImport([]byte(csvFile), 10, 9); err == nil {
+		t.Errorf("resuming past the end: no error")
+	}
+}
+
+func TestImportReportsFileRows(t *testing.T) {
+	file, err := ioutil.TempFile("", "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	// a comment on two lines puts every later row a line further down the file
+	file.WriteString("CPRNum,VirkNum,CPRNavn,DOW,NoOfHours,Comments\n" +
+		"0101901234,12345678,Bob,2026-01-01,7,\"left early\nsick child\"\n" +
+		"0101901234,12345678,Bob,2026-01-02,7,\n" +
+		"0101901234,12345678,Bob,2026-01-03,7,\n" +
+		"0101901234,12345678,Bob,2026-01-04,25,\n")
+	file.Close()
+
+	peer := newFakePeer()
+	defer peer.Close()
+	c := newClient(profile{Peer: peer.URL, ChaincodeName: "cc", SecureContext: "acme"})
+	var out bytes.Buffer
+	stdout, stderr = &out, &out
+
+	peer.result = "tx1"
+	err = runImport(c, []string{"-file", file.Name(), "-batch-size", "2"})
+	if err != nil || peer.request.Method != "invoke" || !strings.Contains(out.String(), "rows 4-5 submitted as tx1") {
+		t.Errorf("import: %v %q", err, out.String())
+	}
+
+	// the check ahead of the invoke reports the rows, numbered as in the file
+	out.Reset()
+	peer.err = &rpcError{Code: -32003, Message: "Query failure",
+		Data: `{"Error":"1 of 1 rows rejected, nothing was stored","Code":"BATCH_REJECTED","Rows":[{"Row":3,"Error":"NoOfHours must be from 0 to 24: 25","Code":"INVALID_ARGUMENT","Field":"NoOfHours"}]}`}
+	err = runImport(c, []string{"-file", file.Name(), "-batch-size", "2", "-from-row", "4"})
+	if err == nil || peer.request.Method != "query" || peer.request.Params.CtorMsg.Function != "checkBulkAddToLogBog" {
+		t.Errorf("rejected batch: %v %+v", err, peer.request)
+	}
+	if !strings.Contains(out.String(), "row 5 (line 6): INVALID_ARGUMENT: NoOfHours must be from 0 to 24: 25 (NoOfHours)") {
+		t.Errorf("got %q", out.String())
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command logbog calls the LogBog chaincode on a peer's /chaincode endpoint,
+// building the JSON-RPC body from named flags:
+//
+//	logbog [-profile file] [-json] <command> [flags]
+//
+// The peer, the deployed chaincode's name and the secureContext to call it as
+// come from a profile file, by default $LOGBOG_PROFILE or ~/.logbog.json:
+//
+//	{
+//	  "peer": "http://localhost:7050",
+//	  "chaincodeName": "<the name deploy returned>",
+//	  "secureContext": "acme",
+//	  "metadata": "<base64 metadata, such as the logBogKey of a pseudonymised ledger>"
+//	}
+//
+// Query results are pretty-printed, search results as a table; with -json
+// they are written as the chaincode returned them. Invokes print the
+// transaction id the peer returns.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(c *client, args []string) error
+}
+
+var commands = []command{
+	{"login", "log the profile's secureContext in with the peer's /registrar", runLogin},
+	{"add", "add an entry with addToLogBog", addCommand("add", "addToLogBog")},
+	{"upsert", "add or replace an entry with upsertLogBog", addCommand("upsert", "upsertLogBog")},
+	{"import", "add the entries of a CSV or JSON file in batches with bulkAddToLogBog", runImport},
+	{"update", "change fields of an entry with updateLogBog", runUpdate},
+	{"retract", "retract an entry, or delete it with -hard", runRetract},
+	{"search", "search entries by CPR number, VirkNum and date", runSearch},
+	{"export", "write the entries of a VirkNum and period to a CSV, JSON Lines or XLSX file with a manifest", runExport},
+	{"history", "list every version of an entry", runHistory},
+	{"read", "read the value under a key", runRead},
+	{"write", "write a value under a key", runWrite},
+}
+
+// jsonOutput writes results as the chaincode returned them.
+var jsonOutput bool
+
+func main() {
+	profilePath := flag.String("profile", defaultProfilePath(), "profile file naming the peer, chaincode and secureContext")
+	flag.BoolVar(&jsonOutput, "json", false, "write results as JSON instead of pretty-printing them")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != flag.Arg(0) {
+			continue
+		}
+		p, err := loadProfile(*profilePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "logbog: "+err.Error())
+			os.Exit(1)
+		}
+		err = cmd.run(newClient(p), flag.Args()[1:])
+		if err == flag.ErrHelp {
+			os.Exit(2)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "logbog: "+err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintln(os.Stderr, "logbog: unknown command "+flag.Arg(0))
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: logbog [-profile file] [-json] <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	flag.PrintDefaults()
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run logbog <command> -h for the flags of a command.")
+}
@@ -0,0 +1,110 @@
+/*
+Copyright IBM Corp 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// stdout and stderr are where commands write to.
+var (
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+// entry is the SKATEmployee record the chaincode returns.
+type entry struct {
+	CPRNum         int      `json:"CPRNum"`
+	CPRHash        string   `json:"CPRHash"`
+	VirkNum        int      `json:"VirkNum"`
+	CPRNavn        string   `json:"CPRNavn"`
+	DateOfWork     string   `json:"DOW"`
+	NoOfHours      float64  `json:"NoOfHours"`
+	Comment        string   `json:"Comments"`
+	StartTime      string   `json:"StartTime"`
+	EndTime        string   `json:"EndTime"`
+	Retracted      bool     `json:"Retracted"`
+	RuleViolations []string `json:"RuleViolations"`
+	Version        int      `json:"Version"`
+}
+
+type entryPage struct {
+	Records   []entry `json:"records"`
+	NextToken string  `json:"nextToken"`
+	Total     int     `json:"total"`
+}
+
+// printInvoke prints the transaction id of a submitted invoke.
+func printInvoke(txID string, err error) error {
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return json.NewEncoder(stdout).Encode(map[string]string{"txid": txID})
+	}
+	fmt.Fprintln(stdout, "submitted "+txID)
+	return nil
+}
+
+func printRaw(result []byte) error {
+	fmt.Fprintln(stdout, string(result))
+	return nil
+}
+
+// printJSON indents a JSON result; anything else is printed as it is.
+func printJSON(result []byte) error {
+	if jsonOutput {
+		return printRaw(result)
+	}
+	var indented bytes.Buffer
+	if json.Indent(&indented, result, "", "  ") != nil {
+		return printRaw(result)
+	}
+	return printRaw(indented.Bytes())
+}
+
+func printEntries(entries []entry) {
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DOW\tCPR\tVIRK\tHOURS\tSHIFT\tNAME\tCOMMENT\tFLAGS")
+	for _, e := range entries {
+		cpr := fmt.Sprintf("%010d", e.CPRNum)
+		if e.CPRNum == 0 {
+			cpr = e.CPRHash
+		}
+		shift := ""
+		if len(e.StartTime) > 0 {
+			// HH:MM shifts are stored on DateOfWork, which is shown already
+			shift = strings.TrimPrefix(e.StartTime, e.DateOfWork+"T") + "-" + strings.TrimPrefix(e.EndTime, e.DateOfWork+"T")
+		}
+		flags := ""
+		if e.Retracted {
+			flags = "retracted"
+		}
+		if len(e.RuleViolations) > 0 {
+			flags = strings.TrimSpace(flags + " " + strconv.Itoa(len(e.RuleViolations)) + " rule violations")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", e.DateOfWork, cpr, e.VirkNum, strconv.FormatFloat(e.NoOfHours, 'f', -1, 64), shift, e.CPRNavn, e.Comment, flags)
+	}
+	w.Flush()
+}